@@ -0,0 +1,122 @@
+package gssapi
+
+// This file provides support for anonymous authentication, as described in
+// RFC 6680 section 3 and, for Kerberos, RFC 8062 (anonymous PKINIT).
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// The well-known anonymous Kerberos principal, from RFC 8062.
+const anonymousPrincipal = "WELLKNOWN/ANONYMOUS@WELLKNOWN:ANONYMOUS"
+
+// ErrAnonymousPeer is returned by CheckAnonymous when the peer authenticated
+// anonymously and the policy does not allow it.
+var ErrAnonymousPeer = errors.New("anonymous peer not allowed")
+
+// AnonymousPolicy says whether an acceptor accepts anonymous initiators.
+type AnonymousPolicy int
+
+const (
+	// AnonymousDeny refuses anonymous initiators. It is the zero value.
+	AnonymousDeny AnonymousPolicy = iota
+	// AnonymousAllow accepts anonymous initiators.
+	AnonymousAllow
+)
+
+// MakeAnonymousName returns a Name of type GSS_C_NT_ANONYMOUS. The return
+// value must be .Release()-ed
+func MakeAnonymousName() (*Name, error) {
+	b, err := MakeBufferString(anonymousPrincipal)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Release()
+
+	return b.Name(GSS_C_NT_ANONYMOUS)
+}
+
+// AcquireAnonymousCred acquires initiator credentials for the anonymous
+// principal. With the krb5 mechanism this performs anonymous PKINIT, so the
+// KDC must have it enabled. outputCredHandle must be .Release()-ed by the
+// caller
+func AcquireAnonymousCred(timeReq time.Duration) (
+	outputCredHandle *CredId, timeRec time.Duration, err error) {
+
+	name, err := MakeAnonymousName()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer name.Release()
+
+	mechs, err := MakeOIDSet(GSS_MECH_KRB5)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer mechs.Release()
+
+	cred, actualMechs, timeRec, err := AcquireCred(name, timeReq, mechs, GSS_C_INITIATE)
+	if err != nil {
+		return nil, 0, err
+	}
+	actualMechs.Release()
+
+	return cred, timeRec, nil
+}
+
+// InitAnonymousSecContext is InitSecContext with GSS_C_ANON_FLAG always
+// requested, using anonymous credentials from AcquireAnonymousCred. The
+// acceptor sees the anonymous principal rather than a real identity, but the
+// resulting context still protects messages with Wrap and GetMIC.
+func InitAnonymousSecContext(anonCredHandle *CredId, ctxIn *CtxId,
+	targetName *Name, reqFlags uint32, timeReq time.Duration,
+	inputChanBindings ChannelBindings, inputToken *Buffer) (
+	ctxOut *CtxId, actualMechType *OID, outputToken *Buffer, retFlags uint32,
+	timeRec time.Duration, err error) {
+
+	return InitSecContext(anonCredHandle, ctxIn, targetName, GSS_MECH_KRB5,
+		reqFlags|GSS_C_ANON_FLAG, timeReq, inputChanBindings, inputToken)
+}
+
+// IsAnonymous reports whether the name is the anonymous principal, either by
+// its name type or by its Kerberos well-known form.
+func (n Name) IsAnonymous() (bool, error) {
+	s, oid, err := n.Display()
+	if err != nil {
+		return false, err
+	}
+	defer oid.Release()
+
+	if oid.C_gss_OID != nil && bytes.Equal(oid.Bytes(), GSS_C_NT_ANONYMOUS.Bytes()) {
+		return true, nil
+	}
+	return s == anonymousPrincipal, nil
+}
+
+// IsAnonymousPeer reports whether an accepted context was established by an
+// anonymous initiator, given the srcName and retFlags returned by
+// AcceptSecContext.
+func IsAnonymousPeer(srcName *Name, retFlags uint32) (bool, error) {
+	if retFlags&GSS_C_ANON_FLAG != 0 {
+		return true, nil
+	}
+	if srcName == nil || srcName.C_gss_name_t == nil {
+		return false, nil
+	}
+	return srcName.IsAnonymous()
+}
+
+// CheckAnonymous applies policy to an accepted context. It returns
+// ErrAnonymousPeer if the initiator is anonymous and policy is AnonymousDeny.
+func CheckAnonymous(policy AnonymousPolicy, srcName *Name, retFlags uint32) error {
+	anon, err := IsAnonymousPeer(srcName, retFlags)
+	if err != nil {
+		return err
+	}
+	if anon && policy != AnonymousAllow {
+		return ErrAnonymousPeer
+	}
+	return nil
+}
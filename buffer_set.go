@@ -0,0 +1,43 @@
+package gssapi
+
+/*
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+
+gss_buffer_t
+get_buffer_set_member(
+	gss_buffer_set_t set,
+	size_t index)
+{
+	return &(set->elements[index]);
+}
+
+size_t
+get_buffer_set_count(gss_buffer_set_t set)
+{
+	return set == GSS_C_NO_BUFFER_SET ? 0 : set->count;
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+// bufferSetBytes copies the members of a gss_buffer_set_t into Go memory and
+// releases the set.
+func bufferSetBytes(set C.gss_buffer_set_t) ([][]byte, error) {
+	n := int(C.get_buffer_set_count(set))
+	values := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		b := C.get_buffer_set_member(set, C.size_t(i))
+		values = append(values, C.GoBytes(unsafe.Pointer(b.value), C.int(b.length)))
+	}
+
+	if set == nil {
+		return values, nil
+	}
+	min := C.OM_uint32(0)
+	maj := C.gss_release_buffer_set(&min, &set)
+	return values, StashLastStatus(maj, min)
+}
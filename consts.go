@@ -27,6 +27,18 @@ const (
 	GSS_C_ANON_FLAG              = 64
 	GSS_C_PROT_READY_FLAG        = 128
 	GSS_C_TRANS_FLAG             = 256
+
+	// Extensions found in both MIT Kerberos and Heimdal.
+	GSS_C_DCE_STYLE           = 4096
+	GSS_C_IDENTIFY_FLAG       = 8192
+	GSS_C_EXTENDED_ERROR_FLAG = 16384
+	GSS_C_DELEG_POLICY_FLAG   = 32768
+)
+
+// Key selection for gss_pseudo_random, from RFC 4401
+const (
+	GSS_C_PRF_KEY_FULL    = 0
+	GSS_C_PRF_KEY_PARTIAL = 1
 )
 
 // Credential usage options
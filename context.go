@@ -26,6 +26,35 @@ func InitSecContext(initiatorCredHandle *CredId, ctxIn *CtxId,
 	ctxOut *CtxId, actualMechType *OID, outputToken *Buffer, retFlags uint32,
 	timeRec time.Duration, err error) {
 
+	// prepare the outputs.
+	if ctxIn != nil {
+		ctxCopy := *ctxIn
+		ctxOut = &ctxCopy
+	} else {
+		ctxOut = NewCtxId()
+	}
+
+	actualMechType, outputToken, retFlags, timeRec, err = initSecContext(
+		initiatorCredHandle, ctxOut, targetName, mechType, reqFlags, timeReq,
+		inputChanBindings, inputToken)
+	if err != nil && err != ErrContinueNeeded {
+		outputToken.Release()
+		return nil, nil, nil, 0, 0, err
+	}
+
+	return ctxOut, actualMechType, outputToken, retFlags, timeRec, err
+}
+
+// initSecContext calls gss_init_sec_context on ctx in place, so that ctx
+// holds whatever handle the library left in it, even on error. The output
+// token is returned on error too, as it may hold an error token for the
+// peer; it is nil only if it could not be allocated.
+func initSecContext(initiatorCredHandle *CredId, ctx *CtxId,
+	targetName *Name, mechType *OID, reqFlags uint32, timeReq time.Duration,
+	inputChanBindings ChannelBindings, inputToken *Buffer) (
+	actualMechType *OID, outputToken *Buffer, retFlags uint32,
+	timeRec time.Duration, err error) {
+
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
@@ -46,18 +75,11 @@ func InitSecContext(initiatorCredHandle *CredId, ctxIn *CtxId,
 	}
 
 	// prepare the outputs.
-	if ctxIn != nil {
-		ctxCopy := *ctxIn
-		ctxOut = &ctxCopy
-	} else {
-		ctxOut = NewCtxId()
-	}
-
 	min := C.OM_uint32(0)
 	actualMechType = NewOID()
 	outputToken, err = MakeBuffer(allocGSSAPI)
 	if err != nil {
-		return nil, nil, nil, 0, 0, err
+		return nil, nil, 0, 0, err
 	}
 
 	flags := C.OM_uint32(0)
@@ -65,7 +87,7 @@ func InitSecContext(initiatorCredHandle *CredId, ctxIn *CtxId,
 
 	maj := C.gss_init_sec_context(  &min,
 									C_initiator,
-									&ctxOut.C_gss_ctx_id_t, // used as both in and out param
+									&ctx.C_gss_ctx_id_t, // used as both in and out param
 									targetName.C_gss_name_t,
 									C_mechType,
 									C.OM_uint32(reqFlags),
@@ -78,14 +100,14 @@ func InitSecContext(initiatorCredHandle *CredId, ctxIn *CtxId,
 									&timerec)
 	err = StashLastStatus(maj, min)
 	if err != nil {
-		return nil, nil, nil, 0, 0, err
+		return nil, outputToken, 0, 0, err
 	}
 
 	if MajorStatus(maj).ContinueNeeded() {
 		err = ErrContinueNeeded
 	}
 
-	return ctxOut, actualMechType, outputToken,
+	return actualMechType, outputToken,
 		uint32(flags), time.Duration(timerec) * time.Second,
 		err
 }
@@ -100,6 +122,34 @@ func AcceptSecContext(
 	retFlags uint32, timeRec time.Duration, delegatedCredHandle *CredId,
 	err error) {
 
+	// prepare the outputs
+	if ctxIn != nil {
+		ctxCopy := *ctxIn
+		ctxOut = &ctxCopy
+	} else {
+		ctxOut = NewCtxId()
+	}
+
+	srcName, actualMechType, outputToken, retFlags, timeRec,
+		delegatedCredHandle, err = acceptSecContext(ctxOut, acceptorCredHandle,
+		inputToken, inputChanBindings)
+	if err != nil && err != ErrContinueNeeded {
+		outputToken.Release()
+		return nil, nil, nil, nil, 0, 0, nil, err
+	}
+
+	return ctxOut, srcName, actualMechType, outputToken, retFlags, timeRec,
+		delegatedCredHandle, err
+}
+
+// acceptSecContext calls gss_accept_sec_context on ctx in place, like
+// initSecContext.
+func acceptSecContext(ctx *CtxId, acceptorCredHandle *CredId,
+	inputToken *Buffer, inputChanBindings ChannelBindings) (
+	srcName *Name, actualMechType *OID, outputToken *Buffer,
+	retFlags uint32, timeRec time.Duration, delegatedCredHandle *CredId,
+	err error) {
+
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
@@ -115,19 +165,12 @@ func AcceptSecContext(
 	}
 
 	// prepare the outputs
-	if ctxIn != nil {
-		ctxCopy := *ctxIn
-		ctxOut = &ctxCopy
-	} else {
-		ctxOut = GSS_C_NO_CONTEXT
-	}
-
 	min := C.OM_uint32(0)
 	srcName = NewName()
 	actualMechType = NewOID()
 	outputToken, err = MakeBuffer(allocGSSAPI)
 	if err != nil {
-		return nil, nil, nil, nil, 0, 0, nil, err
+		return nil, nil, nil, 0, 0, nil, err
 	}
 	flags := C.OM_uint32(0)
 	timerec := C.OM_uint32(0)
@@ -135,7 +178,7 @@ func AcceptSecContext(
 
 	maj := C.gss_accept_sec_context(
 		&min,
-		&ctxOut.C_gss_ctx_id_t, // used as both in and out param
+		&ctx.C_gss_ctx_id_t, // used as both in and out param
 		C_acceptorCredHandle,
 		C_inputToken,
 		C.gss_channel_bindings_t(inputChanBindings),
@@ -148,20 +191,21 @@ func AcceptSecContext(
 
 	err = StashLastStatus(maj, min)
 	if err != nil {
-		return nil, nil, nil, nil, 0, 0, nil, err
+		return nil, nil, outputToken, 0, 0, nil, err
 	}
 
 	if MajorStatus(maj).ContinueNeeded() {
 		err = ErrContinueNeeded
 	}
 
-	return ctxOut, srcName, actualMechType, outputToken, uint32(flags),
+	return srcName, actualMechType, outputToken, uint32(flags),
 		time.Duration(timerec) * time.Second, delegatedCredHandle, err
 }
 
 // DeleteSecContext frees a security context.
 // NB: I decided not to implement the outputToken parameter since its use is no
-// longer recommended, and it would have to be Released by the caller
+// longer recommended, and it would have to be Released by the caller; see
+// DeleteSecContextToken for protocols that still need it.
 func (ctx *CtxId) DeleteSecContext() error {
 	if ctx == nil || ctx.C_gss_ctx_id_t == nil {
 		return nil
@@ -181,6 +225,58 @@ func (ctx *CtxId) Release() error {
 	return ctx.DeleteSecContext()
 }
 
+// DeleteSecContextToken frees a security context like DeleteSecContext, and
+// returns the context deletion token to send to the peer, for mechanisms
+// that produce one. Most, krb5 among them, no longer do, in which case the
+// token is empty and the peer has to be told by other means.
+// The token must be .Release()-ed by the caller
+func (ctx *CtxId) DeleteSecContextToken() (token *Buffer, err error) {
+	token, err = MakeBuffer(allocGSSAPI)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil || ctx.C_gss_ctx_id_t == nil {
+		return token, nil
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	min := C.OM_uint32(0)
+	maj := C.gss_delete_sec_context(&min, &ctx.C_gss_ctx_id_t, token.C_gss_buffer_t)
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		token.Release()
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ProcessContextToken implements gss_process_context_token, passing a
+// context-level token from the peer, such as a deletion token, to the
+// mechanism. After a deletion token the context is marked as terminated:
+// later calls on it fail with GSS_S_NO_CONTEXT, and it still has to be
+// released with DeleteSecContext. A nil or deleted context fails with
+// GSS_S_NO_CONTEXT.
+func (ctx *CtxId) ProcessContextToken(token *Buffer) error {
+	if ctx == nil || ctx.C_gss_ctx_id_t == nil {
+		return StashLastStatus(C.OM_uint32(GSS_S_NO_CONTEXT), 0)
+	}
+	C_token := C.gss_buffer_t(nil)
+	if token != nil {
+		C_token = token.C_gss_buffer_t
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	min := C.OM_uint32(0)
+	maj := C.gss_process_context_token(&min, ctx.C_gss_ctx_id_t, C_token)
+
+	return StashLastStatus(maj, min)
+}
+
 // InquireContext returns fields about a security context.
 func (ctx *CtxId) InquireContext() (
 	srcName *Name, targetName *Name, lifetimeRec time.Duration, mechType *OID,
@@ -0,0 +1,87 @@
+package gssapi
+
+// This file provides security context export and import, for handing an
+// established context from one process to another.
+
+/*
+#include <gssapi/gssapi.h>
+*/
+import "C"
+
+// maxContextTokenSize bounds the size of a context token read by
+// ReceiveSecContext.
+const maxContextTokenSize = 1 << 20
+
+// Export implements gss_export_sec_context. The context is deactivated: on
+// success ctx no longer refers to it and the token is the only way to use it,
+// by importing it in this or another process. The token holds the session
+// keys, so it must be protected accordingly.
+// The token must be .Release()-ed by the caller
+func (ctx *CtxId) Export() (token *Buffer, err error) {
+	token, err = MakeBuffer(allocGSSAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_export_sec_context(&min, &ctx.C_gss_ctx_id_t, token.C_gss_buffer_t)
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		token.Release()
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ImportSecContext implements gss_import_sec_context, turning a token made
+// by CtxId.Export back into a context. The context must be .Release()-ed by
+// the caller
+func ImportSecContext(token *Buffer) (*CtxId, error) {
+	ctx := NewCtxId()
+
+	min := C.OM_uint32(0)
+	maj := C.gss_import_sec_context(&min, token.C_gss_buffer_t, &ctx.C_gss_ctx_id_t)
+	err := StashLastStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using Export. Unlike
+// most marshalers it consumes ctx, which can no longer be used afterwards.
+func (ctx *CtxId) MarshalBinary() ([]byte, error) {
+	token, err := ctx.Export()
+	if err != nil {
+		return nil, err
+	}
+	defer token.Release()
+
+	return token.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using
+// ImportSecContext. Any context previously held by ctx is deleted.
+func (ctx *CtxId) UnmarshalBinary(data []byte) error {
+	token, err := MakeBufferBytes(data)
+	if err != nil {
+		return err
+	}
+	defer token.Release()
+
+	imported, err := ImportSecContext(token)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.DeleteSecContext()
+	if err != nil {
+		imported.DeleteSecContext()
+		return err
+	}
+	ctx.C_gss_ctx_id_t = imported.C_gss_ctx_id_t
+
+	return nil
+}
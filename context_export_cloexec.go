@@ -0,0 +1,13 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+package gssapi
+
+import "syscall"
+
+// recvmsgCloexec receives a message and any file descriptors with it, which
+// the kernel makes close-on-exec as it installs them.
+func recvmsgCloexec(fd int, p, oob []byte) (n, oobn int, err error) {
+	n, oobn, _, _, err = syscall.Recvmsg(fd, p, oob, syscall.MSG_CMSG_CLOEXEC)
+	return n, oobn, err
+}
@@ -0,0 +1,31 @@
+//go:build darwin || solaris
+// +build darwin solaris
+
+package gssapi
+
+import "syscall"
+
+// recvmsgCloexec receives a message and any file descriptors with it, and
+// makes them close-on-exec. There is no MSG_CMSG_CLOEXEC here, so ForkLock
+// is held until that is done, keeping the descriptors from a concurrent
+// fork/exec.
+func recvmsgCloexec(fd int, p, oob []byte) (n, oobn int, err error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+
+	n, oobn, _, _, err = syscall.Recvmsg(fd, p, oob, 0)
+	if err != nil {
+		return n, oobn, err
+	}
+
+	msgs, perr := syscall.ParseSocketControlMessage(oob[:oobn])
+	if perr == nil {
+		for i := range msgs {
+			fds, _ := syscall.ParseUnixRights(&msgs[i])
+			for _, fd := range fds {
+				syscall.CloseOnExec(fd)
+			}
+		}
+	}
+	return n, oobn, nil
+}
@@ -0,0 +1,107 @@
+package gssapi
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSendReceiveSecContext(t *testing.T) {
+	ini, acc := newTestContexts(t,
+		uint32(GSS_C_MUTUAL_FLAG|GSS_C_INTEG_FLAG|GSS_C_CONF_FLAG))
+	defer acc.DeleteSecContext()
+
+	parent, child, err := CredSocketPair()
+	if err != nil {
+		ini.DeleteSecContext()
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	c, err := net.FileConn(child)
+	child.Close()
+	if err != nil {
+		ini.DeleteSecContext()
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		ini.DeleteSecContext()
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	err = SendSecContext(parent, ini, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, f, err := ReceiveSecContext(c.(*net.UnixConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.DeleteSecContext()
+	defer f.Close()
+
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_GETFD, 0)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if flags&syscall.FD_CLOEXEC == 0 {
+		t.Error("received descriptor is not close-on-exec")
+	}
+
+	// the received file is the pipe's write end
+	_, err = f.Write([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b [1]byte
+	_, err = r.Read(b[:])
+	if err != nil || b[0] != 'x' {
+		t.Errorf("read %q, %v from the pipe", b[:], err)
+	}
+
+	// and the received context still talks to the acceptor
+	msg, err := MakeBufferString("message")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.Release()
+	_, token, err := ctx.Wrap(true, GSS_C_QOP_DEFAULT, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Release()
+	out, _, _, err := acc.Unwrap(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Release()
+	if out.String() != "message" {
+		t.Errorf("Unwrap = %q, want %q", out.String(), "message")
+	}
+}
+
+func TestReceiveSecContextNotStream(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syscall.Close(fds[1])
+	f := os.NewFile(uintptr(fds[0]), "dgram")
+	c, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, _, err = ReceiveSecContext(c.(*net.UnixConn))
+	if err == nil {
+		t.Error("ReceiveSecContext accepted a SOCK_DGRAM socket")
+	}
+}
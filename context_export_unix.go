@@ -0,0 +1,180 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gssapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// SendSecContext hands an established context, together with the
+// connection it belongs to, to another process over the Unix socket sock:
+// the context is exported and sent along with the connection's file
+// descriptor (SCM_RIGHTS). This suits a pre-fork server where a privileged
+// listener completes the handshake and a worker serves the session, calling
+// ReceiveSecContext at its end.
+//
+// sock must be a SOCK_STREAM socket, such as one from CredSocketPair: the
+// context is framed by its length and may arrive in pieces.
+//
+// ctx is consumed by the export, even if sending then fails. conn is left
+// open; the caller should close its copy once the context has been sent.
+func SendSecContext(sock *net.UnixConn, ctx *CtxId, conn syscall.Conn) error {
+	err := checkStream(sock)
+	if err != nil {
+		return err
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	data, err := ctx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	msg := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(msg, uint32(len(data)))
+	copy(msg[4:], data)
+
+	var werr error
+	err = raw.Control(func(fd uintptr) {
+		var n int
+		n, _, werr = sock.WriteMsgUnix(msg, syscall.UnixRights(int(fd)), nil)
+		if werr == nil && n < len(msg) {
+			_, werr = sock.Write(msg[n:])
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return werr
+}
+
+// ReceiveSecContext receives a context and connection sent by
+// SendSecContext, over a SOCK_STREAM socket. The connection comes back as a
+// file, which net.FileConn turns into a net.Conn; its descriptor is
+// close-on-exec from the moment it is received. The context must be
+// .Release()-ed and the file closed by the caller
+func ReceiveSecContext(sock *net.UnixConn) (*CtxId, *os.File, error) {
+	err := checkStream(sock)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := sock.SyscallConn()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var hdr [4]byte
+	oob := make([]byte, syscall.CmsgSpace(4))
+	var n, oobn int
+	var rerr error
+	err = raw.Read(func(fd uintptr) bool {
+		for {
+			n, oobn, rerr = recvmsgCloexec(int(fd), hdr[:], oob)
+			if rerr != syscall.EINTR {
+				return rerr != syscall.EAGAIN
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if rerr != nil {
+		return nil, nil, os.NewSyscallError("recvmsg", rerr)
+	}
+	if n == 0 && oobn == 0 {
+		return nil, nil, io.EOF
+	}
+
+	f, err := parseConnRights(oob[:oobn])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = io.ReadFull(sock, hdr[n:])
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	l := binary.BigEndian.Uint32(hdr[:])
+	if l > maxContextTokenSize {
+		f.Close()
+		return nil, nil, fmt.Errorf("context token too large (%d bytes)", l)
+	}
+
+	data := make([]byte, l)
+	_, err = io.ReadFull(sock, data)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	ctx := NewCtxId()
+	err = ctx.UnmarshalBinary(data)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return ctx, f, nil
+}
+
+// parseConnRights returns the single file descriptor passed in oob.
+func parseConnRights(oob []byte) (*os.File, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, os.NewSyscallError("parse socket control message", err)
+	}
+
+	var fds []int
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err == nil {
+			fds = append(fds, rights...)
+		}
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, errors.New("expected one file descriptor with the context")
+	}
+
+	return os.NewFile(uintptr(fds[0]), "gssapi-context-conn"), nil
+}
+
+// checkStream returns an error unless sock is a SOCK_STREAM socket.
+func checkStream(sock *net.UnixConn) error {
+	raw, err := sock.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var typ int
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		typ, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TYPE)
+	})
+	if err != nil {
+		return err
+	}
+	if serr != nil {
+		return os.NewSyscallError("getsockopt", serr)
+	}
+	if typ != syscall.SOCK_STREAM {
+		return fmt.Errorf("socket type %d is not SOCK_STREAM", typ)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package gssapi
+
+// This file provides a snapshot of a security context and a way to act on
+// its expiry.
+
+/*
+#include <gssapi/gssapi.h>
+*/
+import "C"
+
+import (
+	"time"
+)
+
+// A ContextInfo is a snapshot of what InquireContext returns. The names are
+// in their displayed form, so it holds no handles that need releasing.
+type ContextInfo struct {
+	Initiator        string
+	Acceptor         string
+	Mech             *OID
+	Flags            ContextFlags
+	Expiry           time.Time // the zero time if the context does not expire
+	LocallyInitiated bool
+	Open             bool
+}
+
+// Info returns a snapshot of the state of the context.
+func (ctx *CtxId) Info() (*ContextInfo, error) {
+	srcName, targetName, lifetime, mech, flags, li, open, err := ctx.InquireContext()
+	if err != nil {
+		return nil, err
+	}
+	defer srcName.Release()
+	defer targetName.Release()
+
+	info := &ContextInfo{
+		Mech:             mech,
+		Flags:            ContextFlags(flags),
+		Expiry:           expiryFor(lifetime),
+		LocallyInitiated: li,
+		Open:             open,
+	}
+	if srcName.C_gss_name_t != nil {
+		info.Initiator = srcName.String()
+	}
+	if targetName.C_gss_name_t != nil {
+		info.Acceptor = targetName.String()
+	}
+
+	return info, nil
+}
+
+// TimeRemaining returns how long the context will remain valid, using
+// gss_context_time. It returns 0 for an expired context and
+// GSS_C_INDEFINITE for one that does not expire.
+func (ctx *CtxId) TimeRemaining() (time.Duration, error) {
+	min := C.OM_uint32(0)
+	rec := C.OM_uint32(0)
+	maj := C.gss_context_time(&min, ctx.C_gss_ctx_id_t, &rec)
+
+	if MajorStatus(maj).RoutineError() == GSS_S_CONTEXT_EXPIRED {
+		return 0, nil
+	}
+	err := StashLastStatus(maj, min)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(rec) * time.Second, nil
+}
+
+// An ExpiryWatcher signals shortly before a security context expires, so
+// that a long-lived session can re-authenticate ahead of time.
+type ExpiryWatcher struct {
+	// C is closed when the warning time is reached. It is never closed
+	// for a context that does not expire.
+	C <-chan struct{}
+
+	// Expiry is when the context expires, or the zero time if it does
+	// not.
+	Expiry time.Time
+
+	timer *time.Timer
+}
+
+// WatchExpiry returns an ExpiryWatcher that closes its channel, and calls fn
+// if it is not nil, ahead of the expiry of ctx by the duration before. The
+// remaining time is read once, here, so the context is not used from another
+// goroutine later.
+func (ctx *CtxId) WatchExpiry(before time.Duration, fn func()) (*ExpiryWatcher, error) {
+	remaining, err := ctx.TimeRemaining()
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan struct{})
+	w := &ExpiryWatcher{C: c}
+	if remaining >= GSS_C_INDEFINITE {
+		return w, nil
+	}
+
+	w.Expiry = time.Now().Add(remaining)
+	w.timer = time.AfterFunc(remaining-before, func() {
+		close(c)
+		if fn != nil {
+			fn()
+		}
+	})
+
+	return w, nil
+}
+
+// Stop stops the watcher. It reports whether this prevented the channel
+// from being closed.
+func (w *ExpiryWatcher) Stop() bool {
+	if w.timer == nil {
+		return false
+	}
+	return w.timer.Stop()
+}
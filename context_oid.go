@@ -0,0 +1,174 @@
+package gssapi
+
+// This file provides mechanism-specific security context inquiries and
+// options, and typed helpers for the krb5 ones.
+
+/*
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+
+// { 1 2 840 113554 1 2 2 5 12 }, MIT
+const gss_OID_desc *_GSS_KRB5_EXTRACT_AUTHTIME = & (gss_OID_desc) { 11, "\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x0c" };
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+)
+
+var (
+	// krb5AuthzDataPrefix is { 1 2 840 113554 1 2 2 5 10 }, to which MIT
+	// appends the authorization data type to inquire about.
+	krb5AuthzDataPrefix = []byte("\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x0a")
+
+	// krb5EnctypePrefix is { 1 2 840 113554 1 2 2 4 }, to which MIT
+	// appends the enctype of a key returned by a session key inquiry.
+	krb5EnctypePrefix = []byte("\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x04")
+)
+
+// InquireSecContextByOID implements gss_inquire_sec_context_by_oid,
+// returning the data for desiredObject as a list of byte slices. Which OIDs
+// are understood depends on the mechanism; others fail with
+// GSS_S_UNAVAILABLE.
+func InquireSecContextByOID(ctx *CtxId, desiredObject *OID) (
+	dataSet [][]byte, err error) {
+
+	min := C.OM_uint32(0)
+	set := C.gss_buffer_set_t(nil)
+
+	maj := C.gss_inquire_sec_context_by_oid(&min,
+		ctx.C_gss_ctx_id_t,
+		desiredObject.C_gss_OID,
+		&set)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return bufferSetBytes(set)
+}
+
+// SetSecContextOption implements gss_set_sec_context_option, setting the
+// mechanism specific option desiredObject on the context. value may be
+// GSS_C_NO_BUFFER for options that take no value.
+func SetSecContextOption(ctx *CtxId, desiredObject *OID, value *Buffer) error {
+	C_value := C.gss_buffer_t(nil)
+	if value != nil {
+		C_value = value.C_gss_buffer_t
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_set_sec_context_option(&min,
+		&ctx.C_gss_ctx_id_t,
+		desiredObject.C_gss_OID,
+		C_value)
+
+	return StashLastStatus(maj, min)
+}
+
+// SessionKey returns the session key of an established context, and its
+// encryption type, using GSS_C_INQ_SSPI_SESSION_KEY. With krb5 this is the
+// subkey negotiated for the context, or the ticket session key if there is
+// none.
+func (ctx *CtxId) SessionKey() (key []byte, etype enctype.Enctype, err error) {
+	return ctx.inquireKey(GSS_C_INQ_SSPI_SESSION_KEY)
+}
+
+// NegoExKey returns the key used to protect NegoEx messages, and its
+// encryption type, using GSS_C_INQ_NEGOEX_KEY.
+func (ctx *CtxId) NegoExKey() (key []byte, etype enctype.Enctype, err error) {
+	return ctx.inquireKey(GSS_C_INQ_NEGOEX_KEY)
+}
+
+// NegoExVerifyKey returns the key used to verify the peer's NegoEx
+// messages, and its encryption type, using GSS_C_INQ_NEGOEX_VERIFY_KEY.
+func (ctx *CtxId) NegoExVerifyKey() (key []byte, etype enctype.Enctype, err error) {
+	return ctx.inquireKey(GSS_C_INQ_NEGOEX_VERIFY_KEY)
+}
+
+// AuthTime returns when the client authenticated to the KDC to get the
+// ticket the context was established with. It is only available to the
+// acceptor.
+func (ctx *CtxId) AuthTime() (time.Time, error) {
+	data, err := InquireSecContextByOID(ctx, &OID{C_gss_OID: C._GSS_KRB5_EXTRACT_AUTHTIME})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(data) != 1 || len(data[0]) != 4 {
+		return time.Time{}, errors.New("unexpected authtime inquiry result")
+	}
+
+	// a krb5_timestamp, in host byte order
+	t := binary.NativeEndian.Uint32(data[0])
+	return time.Unix(int64(t), 0), nil
+}
+
+// AuthData returns the contents of the authorization data elements of type
+// adType in the ticket the context was established with, for instance 128
+// for a Microsoft PAC. Elements inside AD-IF-RELEVANT containers are
+// included. It is only available to the acceptor.
+func (ctx *CtxId) AuthData(adType int32) ([][]byte, error) {
+	oid, err := MakeOIDBytes(append(append([]byte(nil), krb5AuthzDataPrefix...),
+		oidArc(uint32(adType))...))
+	if err != nil {
+		return nil, err
+	}
+	defer oid.Release()
+
+	return InquireSecContextByOID(ctx, oid)
+}
+
+// inquireKey returns a key and its encryption type from a session key
+// inquiry, which yields the key and an OID ending in the enctype.
+func (ctx *CtxId) inquireKey(desiredObject *OID) ([]byte, enctype.Enctype, error) {
+	data, err := InquireSecContextByOID(ctx, desiredObject)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) != 2 || !bytes.HasPrefix(data[1], krb5EnctypePrefix) {
+		return nil, 0, fmt.Errorf("unexpected %s inquiry result", desiredObject.DebugString())
+	}
+
+	etype, ok := parseOIDArc(data[1][len(krb5EnctypePrefix):])
+	if !ok {
+		return nil, 0, fmt.Errorf("malformed enctype in %s inquiry result",
+			desiredObject.DebugString())
+	}
+
+	return data[0], enctype.Enctype(etype), nil
+}
+
+// oidArc encodes one OID arc in base 128.
+func oidArc(v uint32) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v != 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// parseOIDArc decodes a single base 128 OID arc taking up all of b. It
+// rejects leading zero groups and arcs that overflow 32 bits.
+func parseOIDArc(b []byte) (uint32, bool) {
+	if len(b) == 0 || len(b) > 5 || b[0] == 0x80 {
+		return 0, false
+	}
+	if len(b) == 5 && b[0]&0x7f > 0x0f {
+		return 0, false
+	}
+	var v uint32
+	for i, c := range b {
+		v = v<<7 | uint32(c&0x7f)
+		if (c&0x80 == 0) != (i == len(b)-1) {
+			return 0, false
+		}
+	}
+	return v, true
+}
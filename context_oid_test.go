@@ -0,0 +1,88 @@
+package gssapi
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestOIDArc(t *testing.T) {
+	for _, tc := range []struct {
+		v   uint32
+		enc []byte
+	}{
+		{0, []byte{0x00}},
+		{18, []byte{0x12}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{113554, []byte{0x86, 0xf7, 0x12}},
+		{0xffffffff, []byte{0x8f, 0xff, 0xff, 0xff, 0x7f}},
+	} {
+		if got := oidArc(tc.v); !bytes.Equal(got, tc.enc) {
+			t.Errorf("oidArc(%d) = %x, want %x", tc.v, got, tc.enc)
+		}
+		if got, ok := parseOIDArc(tc.enc); !ok || got != tc.v {
+			t.Errorf("parseOIDArc(%x) = %d, %v; want %d", tc.enc, got, ok, tc.v)
+		}
+	}
+
+	for _, v := range []uint32{1, 23, 128, 16383, 16384, 1 << 21, 1<<28 - 1, 1 << 28, 1 << 31} {
+		if got, ok := parseOIDArc(oidArc(v)); !ok || got != v {
+			t.Errorf("parseOIDArc(oidArc(%d)) = %d, %v", v, got, ok)
+		}
+	}
+}
+
+func TestParseOIDArcMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{0x81},                         // unterminated
+		{0x01, 0x02},                   // more than one arc
+		{0x81, 0x80},                   // terminated too late
+		{0x80, 0x01},                   // leading zero group
+		{0x90, 0x80, 0x80, 0x80, 0x00}, // 2^32
+		{0x81, 0x81, 0x81, 0x81, 0x81, 0x01},
+	} {
+		if v, ok := parseOIDArc(b); ok {
+			t.Errorf("parseOIDArc(%x) = %d, want failure", b, v)
+		}
+	}
+}
+
+func TestAuthTime(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	ini, acc := newTestContexts(t, uint32(GSS_C_MUTUAL_FLAG))
+	defer ini.DeleteSecContext()
+	defer acc.DeleteSecContext()
+
+	// the test ticket was issued as it was written
+	got, err := acc.AuthTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("AuthTime = %v, want about %v", got, before)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	ini, acc := newTestContexts(t, uint32(GSS_C_MUTUAL_FLAG))
+	defer ini.DeleteSecContext()
+	defer acc.DeleteSecContext()
+
+	iniKey, iniType, err := ini.SessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accKey, accType, err := acc.SessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ends agree on a subkey, which need not be of the ticket's enctype
+	if iniType == 0 || accType != iniType {
+		t.Errorf("enctypes %v and %v", iniType, accType)
+	}
+	if len(iniKey) == 0 || !bytes.Equal(iniKey, accKey) {
+		t.Errorf("keys %x and %x", iniKey, accKey)
+	}
+}
@@ -0,0 +1,97 @@
+package gssapi
+
+// This file provides the MIT side of CredId.CcacheName: MIT does not tell
+// which credential cache a credential uses, so its tickets are copied into
+// a MEMORY cache instead.
+
+/*
+#cgo linux LDFLAGS: -lkrb5
+
+#include <stdlib.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_krb5.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+// memoryCcacheSeq makes memory credential cache names unique within the
+// process.
+var memoryCcacheSeq uint64
+
+// copyCcacheToMemory copies the tickets of c into a new MEMORY credential
+// cache, initialized for the credential's principal, and returns its name.
+func copyCcacheToMemory(c *CredId) (string, error) {
+	name, _, _, mechs, err := InquireCred(c)
+	if err != nil {
+		return "", err
+	}
+	defer name.Release()
+	mechs.Release()
+
+	principal, oid, err := name.Display()
+	if err != nil {
+		return "", err
+	}
+	oid.Release()
+
+	ccache := fmt.Sprintf("MEMORY:gssapi-%d-%d", os.Getpid(),
+		atomic.AddUint64(&memoryCcacheSeq, 1))
+
+	var ctx C.krb5_context
+	code := C.krb5_init_context(&ctx)
+	if code != 0 {
+		return "", fmt.Errorf("cannot initialize krb5 context (%d)", int32(code))
+	}
+	defer C.krb5_free_context(ctx)
+
+	ccacheError := func(code C.krb5_error_code) error {
+		msg := C.krb5_get_error_message(ctx, code)
+		defer C.krb5_free_error_message(ctx, msg)
+
+		return fmt.Errorf("credential cache %s: %s", ccache, C.GoString(msg))
+	}
+
+	cprinc := C.CString(principal)
+	defer C.free(unsafe.Pointer(cprinc))
+
+	var princ C.krb5_principal
+	code = C.krb5_parse_name(ctx, cprinc, &princ)
+	if code != 0 {
+		return "", ccacheError(code)
+	}
+	defer C.krb5_free_principal(ctx, princ)
+
+	ccname := C.CString(ccache)
+	defer C.free(unsafe.Pointer(ccname))
+
+	var cc C.krb5_ccache
+	code = C.krb5_cc_resolve(ctx, ccname, &cc)
+	if code != 0 {
+		return "", ccacheError(code)
+	}
+
+	code = C.krb5_cc_initialize(ctx, cc, princ)
+	if code != 0 {
+		C.krb5_cc_destroy(ctx, cc)
+		return "", ccacheError(code)
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_krb5_copy_ccache(&min, c.C_gss_cred_id_t, cc)
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		C.krb5_cc_destroy(ctx, cc)
+		return "", err
+	}
+
+	// a MEMORY cache outlives its handles until it is destroyed
+	C.krb5_cc_close(ctx, cc)
+	return ccache, nil
+}
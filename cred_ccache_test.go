@@ -0,0 +1,51 @@
+package gssapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCcacheName(t *testing.T) {
+	k := newTestKrb5(t)
+	ini, acc := k.credentials(t)
+	defer ini.Release()
+	defer acc.Release()
+
+	ccache, err := ini.CcacheName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ccache, "MEMORY:") {
+		t.Fatalf("CcacheName = %q, want a MEMORY cache", ccache)
+	}
+
+	// the copy holds the service ticket, so it establishes a context
+	cred, mechs, _, err := AcquireCredFrom(GSS_C_NO_NAME(), 0,
+		GSS_C_NO_OID_SET, GSS_C_INITIATE, CredStore{CredStoreCcache: ccache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cred.Release()
+	mechs.Release()
+
+	i, err := NewInitiator(cred, testService, GSS_KRB5_NT_PRINCIPAL_NAME,
+		GSS_MECH_KRB5, uint32(GSS_C_MUTUAL_FLAG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	a := NewAcceptor(acc)
+	defer a.Close()
+	err = establish(t, i, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := ini.CcacheName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == ccache {
+		t.Errorf("CcacheName returned %q twice", ccache)
+	}
+}
@@ -0,0 +1,134 @@
+package gssapi
+
+// This file provides credential export and import, for handing credentials
+// from one process to another.
+
+/*
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxCredTokenSize bounds the size of a credential token read by ReadCred.
+const maxCredTokenSize = 1 << 20
+
+// Export implements gss_export_cred. The resulting token holds everything
+// needed to use the credential in another process, which may include secret
+// keys, so it must be protected like the credential cache or keytab itself.
+// The token must be .Release()-ed by the caller
+func (c *CredId) Export() (token *Buffer, err error) {
+	token, err = MakeBuffer(allocGSSAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_export_cred(&min, c.C_gss_cred_id_t, token.C_gss_buffer_t)
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		token.Release()
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ImportCred implements gss_import_cred, turning a token made by
+// CredId.Export back into a credential. The credential must be .Release()-ed
+// by the caller
+func ImportCred(token *Buffer) (*CredId, error) {
+	cred := NewCredId()
+
+	min := C.OM_uint32(0)
+	maj := C.gss_import_cred(&min, token.C_gss_buffer_t, &cred.C_gss_cred_id_t)
+	err := StashLastStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using Export.
+func (c *CredId) MarshalBinary() ([]byte, error) {
+	token, err := c.Export()
+	if err != nil {
+		return nil, err
+	}
+	defer token.Release()
+
+	return token.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using ImportCred. Any
+// credential previously held by c is released.
+func (c *CredId) UnmarshalBinary(data []byte) error {
+	token, err := MakeBufferBytes(data)
+	if err != nil {
+		return err
+	}
+	defer token.Release()
+
+	cred, err := ImportCred(token)
+	if err != nil {
+		return err
+	}
+
+	err = c.Release()
+	if err != nil {
+		cred.Release()
+		return err
+	}
+	c.C_gss_cred_id_t = cred.C_gss_cred_id_t
+
+	return nil
+}
+
+// WriteCred exports cred and writes it to w, prefixed with its length, for
+// ReadCred to pick up at the other end.
+func WriteCred(w io.Writer, cred *CredId) error {
+	data, err := cred.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(data)))
+	_, err = w.Write(append(l[:], data...))
+	return err
+}
+
+// ReadCred reads a credential written by WriteCred from r and imports it.
+// The credential must be .Release()-ed by the caller
+func ReadCred(r io.Reader) (*CredId, error) {
+	var l [4]byte
+	_, err := io.ReadFull(r, l[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(l[:])
+	if n > maxCredTokenSize {
+		return nil, fmt.Errorf("credential token too large (%d bytes)", n)
+	}
+
+	data := make([]byte, n)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cred := NewCredId()
+	err = cred.UnmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
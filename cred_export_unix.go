@@ -0,0 +1,42 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gssapi
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// CredSocketPair returns a connected pair of Unix sockets for handing
+// credentials to a child process. The parent keeps parent and sends with
+// WriteCred; child is meant for exec.Cmd.ExtraFiles, and the child process
+// calls ReadCred on the inherited descriptor. That way the child never reads
+// the keytab or credential cache itself. The caller should close child once
+// the child process has started.
+func CredSocketPair() (parent *net.UnixConn, child *os.File, err error) {
+	// hold ForkLock so that no fork/exec sees the descriptors before they
+	// are close-on-exec; SOCK_CLOEXEC is not available everywhere
+	syscall.ForkLock.RLock()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fds[0])
+		syscall.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "gssapi-cred-parent")
+	defer f.Close()
+
+	conn, err := net.FileConn(f)
+	if err != nil {
+		syscall.Close(fds[1])
+		return nil, nil, err
+	}
+
+	return conn.(*net.UnixConn), os.NewFile(uintptr(fds[1]), "gssapi-cred-child"), nil
+}
@@ -0,0 +1,255 @@
+package gssapi
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// minRenewWait bounds how often a CredManager goes back to its CredSource
+// when the credential lifetime is shorter than the renewal margin.
+const minRenewWait = 10 * time.Second
+
+// ErrCredManagerClosed is returned by CredManager methods after Close.
+var ErrCredManagerClosed = errors.New("credential manager closed")
+
+// A CredSource obtains a fresh credential for a CredManager.
+type CredSource func() (*CredId, error)
+
+// ClientKeytabSource returns a CredSource that gets initiator credentials
+// for desiredName from clientKeytab, keeping the tickets in ccache (for
+// instance "MEMORY:myapp"). desiredName must stay valid while the source is
+// in use.
+func ClientKeytabSource(desiredName *Name, clientKeytab string, ccache string) CredSource {
+	store := CredStore{
+		CredStoreClientKeytab: clientKeytab,
+		CredStoreCcache:       ccache,
+	}
+	return storeSource(desiredName, store)
+}
+
+// CcacheSource returns a CredSource that gets initiator credentials for
+// desiredName from ccache. GSSAPI cannot renew tickets itself, so each call
+// only picks up whatever tickets the cache currently holds; use
+// krb5.RenewingCcacheSource to renew a renewable ticket as well, or keep the
+// cache renewed by other means (kinit -R, k5start, sssd and so on).
+// desiredName must stay valid while the source is in use.
+func CcacheSource(desiredName *Name, ccache string) CredSource {
+	return storeSource(desiredName, CredStore{CredStoreCcache: ccache})
+}
+
+func storeSource(desiredName *Name, store CredStore) CredSource {
+	return func() (*CredId, error) {
+		cred, actualMechs, _, err := AcquireCredFrom(desiredName, 0,
+			GSS_C_NO_OID_SET, GSS_C_INITIATE, store)
+		if err != nil {
+			return nil, err
+		}
+		actualMechs.Release()
+		return cred, nil
+	}
+}
+
+// A CredManager holds a credential and replaces it with a fresh one from its
+// CredSource shortly before it expires, so that long-running clients do not
+// start failing with GSS_S_CREDENTIALS_EXPIRED. The credential is swapped
+// atomically: callers use it through Do, and the old handle is only released
+// once no Do call is using it.
+type CredManager struct {
+	// OnRenew, if set, is called after each successful renewal with the
+	// lifetime of the new credential.
+	OnRenew func(lifetime time.Duration)
+
+	// OnFailure, if set, is called when getting a new credential fails.
+	// The previous credential stays in use until it is replaced.
+	OnFailure func(err error)
+
+	// RetryInterval is how long to wait after a failure before trying
+	// again. It defaults to one minute.
+	RetryInterval time.Duration
+
+	source      CredSource
+	renewBefore time.Duration
+
+	renewMu sync.Mutex
+	mu      sync.RWMutex
+	cred    *CredId
+	expiry  time.Time
+	lastErr error
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCredManager gets a first credential from source and returns a manager
+// for it. The credential is renewed renewBefore its expiry once Start has
+// been called.
+func NewCredManager(source CredSource, renewBefore time.Duration) (*CredManager, error) {
+	m := &CredManager{
+		RetryInterval: time.Minute,
+		source:        source,
+		renewBefore:   renewBefore,
+	}
+
+	cred, lifetime, err := m.acquire()
+	if err != nil {
+		return nil, err
+	}
+	m.cred = cred
+	m.expiry = expiryFor(lifetime)
+
+	return m, nil
+}
+
+// Start starts renewing the credential in the background. Set the callbacks
+// and RetryInterval before calling it.
+func (m *CredManager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed || m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.stop, m.done)
+}
+
+// Do calls fn with the current credential. The credential must not be used
+// or retained after fn returns.
+func (m *CredManager) Do(fn func(cred *CredId) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrCredManagerClosed
+	}
+	return fn(m.cred)
+}
+
+// Expiry returns when the current credential expires (the zero time if it
+// does not), and the error from the last renewal attempt, if it failed.
+func (m *CredManager) Expiry() (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.expiry, m.lastErr
+}
+
+// Renew replaces the credential with a fresh one from the source now.
+func (m *CredManager) Renew() error {
+	m.renewMu.Lock()
+	defer m.renewMu.Unlock()
+
+	cred, lifetime, err := m.acquire()
+	if err != nil {
+		m.mu.Lock()
+		m.lastErr = err
+		m.mu.Unlock()
+
+		if m.OnFailure != nil {
+			m.OnFailure(err)
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cred.Release()
+		return ErrCredManagerClosed
+	}
+	old := m.cred
+	m.cred = cred
+	m.expiry = expiryFor(lifetime)
+	m.lastErr = nil
+	m.mu.Unlock()
+
+	old.Release()
+
+	if m.OnRenew != nil {
+		m.OnRenew(lifetime)
+	}
+	return nil
+}
+
+// Close stops the renewal and releases the credential. It waits for calls
+// to Do in progress to return.
+func (m *CredManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	stop, done := m.stop, m.done
+	m.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	m.renewMu.Lock()
+	defer m.renewMu.Unlock()
+	return m.cred.Release()
+}
+
+func (m *CredManager) acquire() (*CredId, time.Duration, error) {
+	cred, err := m.source()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	name, lifetime, _, mechs, err := InquireCred(cred)
+	if err != nil {
+		cred.Release()
+		return nil, 0, err
+	}
+	name.Release()
+	mechs.Release()
+
+	return cred, lifetime, nil
+}
+
+// expiryFor returns the expiry time for a lifetime, or the zero time if the
+// lifetime is GSS_C_INDEFINITE.
+func expiryFor(lifetime time.Duration) time.Time {
+	if lifetime >= GSS_C_INDEFINITE {
+		return time.Time{}
+	}
+	return time.Now().Add(lifetime)
+}
+
+func (m *CredManager) run(stop, done chan struct{}) {
+	defer close(done)
+
+	for {
+		var wait <-chan time.Time
+
+		m.mu.RLock()
+		switch {
+		case m.lastErr != nil:
+			d := m.RetryInterval
+			if d < minRenewWait {
+				d = minRenewWait
+			}
+			wait = time.After(d)
+		case !m.expiry.IsZero():
+			d := time.Until(m.expiry) - m.renewBefore
+			if d < minRenewWait {
+				d = minRenewWait
+			}
+			wait = time.After(d)
+		}
+		m.mu.RUnlock()
+
+		select {
+		case <-stop:
+			return
+		case <-wait:
+			m.Renew()
+		}
+	}
+}
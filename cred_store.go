@@ -0,0 +1,274 @@
+package gssapi
+
+// This file provides the credential store extensions, which let a caller
+// choose the keytab, credential cache and so on per credential rather than
+// through process-wide environment variables.
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+
+gss_key_value_set_desc *
+alloc_cred_store(OM_uint32 count)
+{
+	gss_key_value_set_desc *s = calloc(1, sizeof(gss_key_value_set_desc));
+	if (s == NULL) {
+		return NULL;
+	}
+	s->elements = calloc(count + 1, sizeof(gss_key_value_element_desc));
+	if (s->elements == NULL) {
+		free(s);
+		return NULL;
+	}
+	s->count = count;
+	return s;
+}
+
+void
+set_cred_store_element(gss_key_value_set_desc *s, OM_uint32 i, char *key, char *value)
+{
+	s->elements[i].key = key;
+	s->elements[i].value = value;
+}
+
+// free_cred_store clears the values before freeing them, since they may
+// contain a password.
+void
+free_cred_store(gss_key_value_set_desc *s)
+{
+	OM_uint32 i;
+	for (i = 0; i < s->count; i++) {
+		char *v = (char *)s->elements[i].value;
+		if (v != NULL) {
+			memset(v, 0, strlen(v));
+		}
+		free((char *)s->elements[i].key);
+		free(v);
+	}
+	free(s->elements);
+	free(s);
+}
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// Well-known credential store keys, as understood by the MIT krb5 mechanism.
+const (
+	CredStoreKeytab       = "keytab"
+	CredStoreClientKeytab = "client_keytab"
+	CredStoreCcache       = "ccache"
+	CredStoreRcache       = "rcache"
+	CredStorePassword     = "password"
+	CredStoreVerify       = "verify"
+)
+
+// A CredStore selects where credentials are acquired from or stored into,
+// for instance {"keytab": "FILE:/etc/http.keytab"}. The keys are mechanism
+// specific; see the CredStore* constants for the ones used by krb5.
+type CredStore map[string]string
+
+// GSS_C_NO_CRED_STORE selects the default credential store.
+var GSS_C_NO_CRED_STORE CredStore
+
+// cStore copies the store into C memory. The result must be freed with
+// C.free_cred_store, unless it is nil (GSS_C_NO_CRED_STORE).
+func (cs CredStore) cStore() (*C.gss_key_value_set_desc, error) {
+	if len(cs) == 0 {
+		return nil, nil
+	}
+
+	s := C.alloc_cred_store(C.OM_uint32(len(cs)))
+	if s == nil {
+		return nil, ErrMallocFailed
+	}
+
+	i := 0
+	for k, v := range cs {
+		C.set_cred_store_element(s, C.OM_uint32(i), C.CString(k), C.CString(v))
+		i++
+	}
+
+	return s, nil
+}
+
+func cBool(b bool) C.OM_uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func freeCredStore(s *C.gss_key_value_set_desc) {
+	if s != nil {
+		C.free_cred_store(s)
+	}
+}
+
+// AcquireCredFrom implements gss_acquire_cred_from, which is AcquireCred
+// with the credentials taken from credStore instead of the process defaults.
+// outputCredHandle, actualMechs must be .Release()-ed by the caller
+func AcquireCredFrom(desiredName *Name, timeReq time.Duration,
+	desiredMechs *OIDSet, credUsage CredUsage, credStore CredStore) (
+	outputCredHandle *CredId, actualMechs *OIDSet, timeRec time.Duration,
+	err error) {
+
+	store, err := credStore.cStore()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer freeCredStore(store)
+
+	min := C.OM_uint32(0)
+	actualMechs = NewOIDSet()
+	outputCredHandle = NewCredId()
+	timerec := C.OM_uint32(0)
+
+	maj := C.gss_acquire_cred_from(&min,
+		desiredName.C_gss_name_t,
+		C.OM_uint32(timeReq.Seconds()),
+		desiredMechs.C_gss_OID_set,
+		C.gss_cred_usage_t(credUsage),
+		C.gss_const_key_value_set_t(unsafe.Pointer(store)),
+		&outputCredHandle.C_gss_cred_id_t,
+		&actualMechs.C_gss_OID_set,
+		&timerec)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return outputCredHandle, actualMechs, time.Duration(timerec) * time.Second, nil
+}
+
+// AddCredFrom implements gss_add_cred_from, which is AddCred with the
+// credentials taken from credStore instead of the process defaults.
+// outputCredHandle, actualMechs must be .Release()-ed by the caller
+func AddCredFrom(inputCredHandle *CredId,
+	desiredName *Name, desiredMech *OID, credUsage CredUsage,
+	initiatorTimeReq time.Duration, acceptorTimeReq time.Duration,
+	credStore CredStore) (
+	outputCredHandle *CredId, actualMechs *OIDSet,
+	initiatorTimeRec time.Duration, acceptorTimeRec time.Duration,
+	err error) {
+
+	store, err := credStore.cStore()
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	defer freeCredStore(store)
+
+	min := C.OM_uint32(0)
+	actualMechs = NewOIDSet()
+	outputCredHandle = NewCredId()
+	initSeconds := C.OM_uint32(0)
+	acceptSeconds := C.OM_uint32(0)
+
+	maj := C.gss_add_cred_from(&min,
+		inputCredHandle.C_gss_cred_id_t,
+		desiredName.C_gss_name_t,
+		desiredMech.C_gss_OID,
+		C.gss_cred_usage_t(credUsage),
+		C.OM_uint32(initiatorTimeReq.Seconds()),
+		C.OM_uint32(acceptorTimeReq.Seconds()),
+		C.gss_const_key_value_set_t(unsafe.Pointer(store)),
+		&outputCredHandle.C_gss_cred_id_t,
+		&actualMechs.C_gss_OID_set,
+		&initSeconds,
+		&acceptSeconds)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	return outputCredHandle,
+		actualMechs,
+		time.Duration(initSeconds) * time.Second,
+		time.Duration(acceptSeconds) * time.Second,
+		nil
+}
+
+// StoreCred implements gss_store_cred. It writes the credentials in
+// inputCredHandle, for instance a delegatedCredHandle from AcceptSecContext,
+// into the default store for the mechanism. overwriteCred allows replacing
+// existing credentials and defaultCred makes them the default. elementsStored
+// must be .Release()-ed by the caller
+func StoreCred(inputCredHandle *CredId, inputUsage CredUsage,
+	desiredMech *OID, overwriteCred bool, defaultCred bool) (
+	elementsStored *OIDSet, credUsageStored CredUsage, err error) {
+
+	C_desiredMech := C.gss_OID(nil)
+	if desiredMech != nil {
+		C_desiredMech = desiredMech.C_gss_OID
+	}
+
+	min := C.OM_uint32(0)
+	elementsStored = NewOIDSet()
+	credUsageStored = CredUsage(0)
+
+	maj := C.gss_store_cred(&min,
+		inputCredHandle.C_gss_cred_id_t,
+		C.gss_cred_usage_t(inputUsage),
+		C_desiredMech,
+		cBool(overwriteCred),
+		cBool(defaultCred),
+		&elementsStored.C_gss_OID_set,
+		(*C.gss_cred_usage_t)(&credUsageStored))
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return elementsStored, credUsageStored, nil
+}
+
+// StoreCredInto implements gss_store_cred_into, which is StoreCred with the
+// destination taken from credStore. For krb5 the destination is given by
+// CredStoreCcache, for instance "FILE:/tmp/krb5cc_app", "DIR:/run/user/cc",
+// "KEYRING:persistent:1000" or "MEMORY:worker". elementsStored must be
+// .Release()-ed by the caller
+func StoreCredInto(inputCredHandle *CredId, inputUsage CredUsage,
+	desiredMech *OID, overwriteCred bool, defaultCred bool,
+	credStore CredStore) (
+	elementsStored *OIDSet, credUsageStored CredUsage, err error) {
+
+	store, err := credStore.cStore()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer freeCredStore(store)
+
+	C_desiredMech := C.gss_OID(nil)
+	if desiredMech != nil {
+		C_desiredMech = desiredMech.C_gss_OID
+	}
+
+	min := C.OM_uint32(0)
+	elementsStored = NewOIDSet()
+	credUsageStored = CredUsage(0)
+
+	maj := C.gss_store_cred_into(&min,
+		inputCredHandle.C_gss_cred_id_t,
+		C.gss_cred_usage_t(inputUsage),
+		C_desiredMech,
+		cBool(overwriteCred),
+		cBool(defaultCred),
+		C.gss_const_key_value_set_t(unsafe.Pointer(store)),
+		&elementsStored.C_gss_OID_set,
+		(*C.gss_cred_usage_t)(&credUsageStored))
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return elementsStored, credUsageStored, nil
+}
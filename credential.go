@@ -2,13 +2,18 @@ package gssapi
 
 /*
 #include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
 */
 import "C"
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrNoData is returned when an inquiry by OID succeeds but returns no data.
+var ErrNoData = errors.New("no data returned")
+
 // NewCredId instantiates a new credential.
 func NewCredId() *CredId {
 	return &CredId{}
@@ -83,6 +88,83 @@ func AddCred(inputCredHandle *CredId,
 		nil
 }
 
+// AcquireCredImpersonateName implements gss_acquire_cred_impersonate_name.
+// It uses impersonatorCredHandle, a service's own credential, to obtain
+// credentials for desiredName without the user's involvement; for Kerberos
+// this is S4U2Self (protocol transition). Used with InitSecContext, the
+// result gives S4U2Proxy (constrained delegation) to the services the KDC
+// allows. outputCredHandle, actualMechs must be .Release()-ed by the caller
+func AcquireCredImpersonateName(impersonatorCredHandle *CredId,
+	desiredName *Name, timeReq time.Duration, desiredMechs *OIDSet,
+	credUsage CredUsage) (outputCredHandle *CredId, actualMechs *OIDSet,
+	timeRec time.Duration, err error) {
+
+	min := C.OM_uint32(0)
+	actualMechs = NewOIDSet()
+	outputCredHandle = NewCredId()
+	timerec := C.OM_uint32(0)
+
+	maj := C.gss_acquire_cred_impersonate_name(&min,
+		impersonatorCredHandle.C_gss_cred_id_t,
+		desiredName.C_gss_name_t,
+		C.OM_uint32(timeReq.Seconds()),
+		desiredMechs.C_gss_OID_set,
+		C.gss_cred_usage_t(credUsage),
+		&outputCredHandle.C_gss_cred_id_t,
+		&actualMechs.C_gss_OID_set,
+		&timerec)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return outputCredHandle, actualMechs, time.Duration(timerec) * time.Second, nil
+}
+
+// AddCredImpersonateName implements gss_add_cred_impersonate_name, which is
+// AddCred with the added element obtained by impersonating desiredName, as
+// in AcquireCredImpersonateName. outputCredHandle, actualMechs must be
+// .Release()-ed by the caller
+func AddCredImpersonateName(inputCredHandle *CredId,
+	impersonatorCredHandle *CredId, desiredName *Name, desiredMech *OID,
+	credUsage CredUsage, initiatorTimeReq time.Duration,
+	acceptorTimeReq time.Duration) (
+	outputCredHandle *CredId, actualMechs *OIDSet,
+	initiatorTimeRec time.Duration, acceptorTimeRec time.Duration,
+	err error) {
+
+	min := C.OM_uint32(0)
+	actualMechs = NewOIDSet()
+	outputCredHandle = NewCredId()
+	initSeconds := C.OM_uint32(0)
+	acceptSeconds := C.OM_uint32(0)
+
+	maj := C.gss_add_cred_impersonate_name(&min,
+		inputCredHandle.C_gss_cred_id_t,
+		impersonatorCredHandle.C_gss_cred_id_t,
+		desiredName.C_gss_name_t,
+		desiredMech.C_gss_OID,
+		C.gss_cred_usage_t(credUsage),
+		C.OM_uint32(initiatorTimeReq.Seconds()),
+		C.OM_uint32(acceptorTimeReq.Seconds()),
+		&outputCredHandle.C_gss_cred_id_t,
+		&actualMechs.C_gss_OID_set,
+		&initSeconds,
+		&acceptSeconds)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	return outputCredHandle,
+		actualMechs,
+		time.Duration(initSeconds) * time.Second,
+		time.Duration(acceptSeconds) * time.Second,
+		nil
+}
+
 // InquireCred implements gss_inquire_cred API, as per
 // https://tools.ietf.org/html/rfc2743#page-34. name and mechanisms must be
 // .Release()-ed by the caller
@@ -160,3 +242,92 @@ func (c *CredId) Release() error {
 }
 
 //TODO: Test for AddCred with existing cred
+
+// InquireCredByOID implements gss_inquire_cred_by_oid, returning the data
+// for desiredObject as a list of byte slices. Which OIDs are understood
+// depends on the mechanism; others fail with GSS_S_UNAVAILABLE.
+func InquireCredByOID(credHandle *CredId, desiredObject *OID) (
+	dataSet [][]byte, err error) {
+
+	min := C.OM_uint32(0)
+	set := C.gss_buffer_set_t(nil)
+
+	maj := C.gss_inquire_cred_by_oid(&min,
+		credHandle.C_gss_cred_id_t,
+		desiredObject.C_gss_OID,
+		&set)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return bufferSetBytes(set)
+}
+
+// SetCredOption implements gss_set_cred_option, setting the mechanism
+// specific option desiredObject on the credential. value may be
+// GSS_C_NO_BUFFER for options that take no value.
+func SetCredOption(credHandle *CredId, desiredObject *OID, value *Buffer) error {
+	C_value := C.gss_buffer_t(nil)
+	if value != nil {
+		C_value = value.C_gss_buffer_t
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_set_cred_option(&min,
+		&credHandle.C_gss_cred_id_t,
+		desiredObject.C_gss_OID,
+		C_value)
+
+	return StashLastStatus(maj, min)
+}
+
+// The typed helpers below cover the krb5 credential OIDs that some
+// implementation answers. There are none for the keytab in use or for
+// turning off the transited realms check: neither MIT nor Heimdal defines a
+// credential OID for them. The keytab is whatever was given to
+// AcquireCredFrom (CredStoreKeytab) or KRB5_KTNAME, and transited realms are
+// checked by the KDC (reject_bad_transit in kdc.conf).
+
+// CcacheName returns the name, as "TYPE:residual", of a credential cache
+// holding the tickets of a krb5 initiator credential. Heimdal answers
+// GSS_KRB5_COPY_CCACHE_X with the cache the credential uses. MIT does not,
+// so there the tickets are copied into a new MEMORY cache of this process,
+// which stays until the caller destroys it with krb5.DestroyCcache.
+func (c *CredId) CcacheName() (string, error) {
+	data, err := InquireCredByOID(c, GSS_KRB5_COPY_CCACHE_X)
+	if e, ok := err.(*Error); ok && e.Major.RoutineError() == GSS_S_UNAVAILABLE {
+		return copyCcacheToMemory(c)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", ErrNoData
+	}
+	return string(data[0]), nil
+}
+
+// Impersonator returns the principal of the service that obtained the
+// credential with AcquireCredImpersonateName, if any, using
+// GSS_KRB5_GET_CRED_IMPERSONATOR.
+func (c *CredId) Impersonator() (string, error) {
+	data, err := InquireCredByOID(c, GSS_KRB5_GET_CRED_IMPERSONATOR)
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", ErrNoData
+	}
+	return string(data[0]), nil
+}
+
+// SetNoCIFlags stops the krb5 mechanism from asking for the
+// GSS_C_CONF_FLAG and GSS_C_INTEG_FLAG services by default on contexts
+// initiated with this credential, using GSS_KRB5_CRED_NO_CI_FLAGS_X. This is
+// needed by some protocols, such as SASL GSSAPI with Active Directory LDAP
+// servers.
+func (c *CredId) SetNoCIFlags() error {
+	return SetCredOption(c, GSS_KRB5_CRED_NO_CI_FLAGS_X, GSS_C_NO_BUFFER)
+}
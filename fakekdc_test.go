@@ -0,0 +1,368 @@
+package gssapi
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/asn1"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// There is no KDC in the test environment, so the helpers below play its
+// part: they write a keytab for a made up service and a credential cache
+// holding a service ticket for it, encrypted in the keytab's key, which the
+// library then uses as if the KDC had issued it. This is enough for the
+// krb5 mechanism to establish real contexts between an initiator and an
+// acceptor in the same process.
+
+const (
+	testRealm   = "EXAMPLE.COM"
+	testClient  = "alice@" + testRealm
+	testService = "host/test.example.com@" + testRealm
+)
+
+// testKrb5 is a keytab and a credential cache for testService, and the
+// configuration the library needs to use them.
+type testKrb5 struct {
+	keytab string
+	ccache string
+}
+
+// newTestKrb5 writes a krb5.conf, a keytab and a credential cache in a
+// temporary directory and points the library at the configuration.
+func newTestKrb5(t *testing.T) *testKrb5 {
+	t.Helper()
+	dir := t.TempDir()
+
+	conf := filepath.Join(dir, "krb5.conf")
+	err := ioutil.WriteFile(conf, []byte(`[libdefaults]
+	default_realm = `+testRealm+`
+	dns_lookup_kdc = false
+	dns_lookup_realm = false
+	rdns = false
+	dns_canonicalize_hostname = false
+	allow_rc4 = true
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KRB5_CONFIG", conf)
+	t.Setenv("KRB5CCNAME", "FILE:"+filepath.Join(dir, "none"))
+	t.Setenv("KRB5_KTNAME", "FILE:"+filepath.Join(dir, "none.keytab"))
+
+	client, err := keytab.ParsePrincipal(testClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := keytab.ParsePrincipal(testService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceKey := randomBytes(t, 16)
+	sessionKey := randomBytes(t, 16)
+	now := time.Now().UTC().Truncate(time.Second)
+	end := now.Add(time.Hour)
+
+	kt := keytab.New()
+	kt.Add(keytab.Entry{
+		Principal: service,
+		Timestamp: now,
+		KVNO:      1,
+		Enctype:   enctype.ARCFOUR_HMAC,
+		Key:       serviceKey,
+	})
+	k := &testKrb5{
+		keytab: filepath.Join(dir, "service.keytab"),
+		ccache: filepath.Join(dir, "ccache"),
+	}
+	err = kt.Write(k.keytab, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := makeTicket(t, client, service, serviceKey, sessionKey, now, end)
+	err = ioutil.WriteFile(k.ccache,
+		makeCcache(client, service, sessionKey, ticket, now, end), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+// credentials returns an initiator credential for testClient and an
+// acceptor credential for testService, which must be released by the
+// caller.
+func (k *testKrb5) credentials(t *testing.T) (initiator, acceptor *CredId) {
+	t.Helper()
+
+	initiator, mechs, _, err := AcquireCredFrom(GSS_C_NO_NAME(), 0,
+		GSS_C_NO_OID_SET, GSS_C_INITIATE, CredStore{CredStoreCcache: "FILE:" + k.ccache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mechs.Release()
+
+	acceptor, mechs, _, err = AcquireCredFrom(GSS_C_NO_NAME(), 0,
+		GSS_C_NO_OID_SET, GSS_C_ACCEPT, CredStore{
+			CredStoreKeytab: "FILE:" + k.keytab,
+			CredStoreRcache: "none:",
+		})
+	if err != nil {
+		initiator.Release()
+		t.Fatal(err)
+	}
+	mechs.Release()
+
+	return initiator, acceptor
+}
+
+// establish runs an Initiator and an Acceptor against each other until the
+// context is established or one of them fails.
+func establish(t *testing.T, ini *Initiator, acc *Acceptor) error {
+	t.Helper()
+
+	var in []byte
+	for i := 0; i < 4; i++ {
+		out, iniDone, err := ini.Step(in)
+		if err != nil {
+			return err
+		}
+		if len(out) == 0 {
+			if iniDone && acc.Done() {
+				return nil
+			}
+			t.Fatal("initiator sent no token")
+		}
+
+		in, _, err = acc.Step(out)
+		if err != nil {
+			return err
+		}
+		if iniDone {
+			return nil
+		}
+		if len(in) == 0 {
+			_, iniDone, err = ini.Step(nil)
+			if err != nil || iniDone {
+				return err
+			}
+		}
+	}
+	t.Fatal("context not established after 4 round trips")
+	return nil
+}
+
+// newTestContexts establishes a krb5 context with reqFlags and returns the
+// initiator's and the acceptor's ends, which must be deleted by the caller.
+func newTestContexts(t *testing.T, reqFlags uint32) (initiator, acceptor *CtxId) {
+	t.Helper()
+
+	k := newTestKrb5(t)
+	iniCred, accCred := k.credentials(t)
+	defer iniCred.Release()
+	defer accCred.Release()
+
+	ini, err := NewInitiator(iniCred, testService, GSS_KRB5_NT_PRINCIPAL_NAME,
+		GSS_MECH_KRB5, reqFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := NewAcceptor(accCred)
+
+	err = establish(t, ini, acc)
+	if err != nil {
+		ini.Close()
+		acc.Close()
+		t.Fatal(err)
+	}
+
+	// take the contexts over from the sessions
+	initiator, acceptor = ini.ctx, acc.ctx
+	ini.ctx, acc.ctx = NewCtxId(), NewCtxId()
+	ini.Close()
+	acc.Close()
+	return initiator, acceptor
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The ASN.1 types of RFC 4120 needed for a ticket.
+
+type asn1PrincipalName struct {
+	NameType   int32           `asn1:"explicit,tag:0"`
+	NameString []asn1.RawValue `asn1:"explicit,tag:1"`
+}
+
+type asn1EncryptionKey struct {
+	KeyType  int32  `asn1:"explicit,tag:0"`
+	KeyValue []byte `asn1:"explicit,tag:1"`
+}
+
+type asn1EncryptedData struct {
+	EType  int32  `asn1:"explicit,tag:0"`
+	KVNO   int    `asn1:"explicit,tag:1"`
+	Cipher []byte `asn1:"explicit,tag:2"`
+}
+
+type asn1TransitedEncoding struct {
+	TRType   int32  `asn1:"explicit,tag:0"`
+	Contents []byte `asn1:"explicit,tag:1"`
+}
+
+type asn1EncTicketPart struct {
+	Flags     asn1.BitString        `asn1:"explicit,tag:0"`
+	Key       asn1EncryptionKey     `asn1:"explicit,tag:1"`
+	CRealm    asn1.RawValue         // explicit tag 2, see realm
+	CName     asn1PrincipalName     `asn1:"explicit,tag:3"`
+	Transited asn1TransitedEncoding `asn1:"explicit,tag:4"`
+	AuthTime  time.Time             `asn1:"generalized,explicit,tag:5"`
+	StartTime time.Time             `asn1:"generalized,explicit,tag:6"`
+	EndTime   time.Time             `asn1:"generalized,explicit,tag:7"`
+}
+
+type asn1Ticket struct {
+	TktVNO  int               `asn1:"explicit,tag:0"`
+	Realm   asn1.RawValue     // explicit tag 1, see realm
+	SName   asn1PrincipalName `asn1:"explicit,tag:2"`
+	EncPart asn1EncryptedData `asn1:"explicit,tag:3"`
+}
+
+func generalString(s string) asn1.RawValue {
+	return asn1.RawValue{Tag: 27, Bytes: []byte(s)}
+}
+
+// realm returns a realm with an explicit tag, which encoding/asn1 does not
+// add to a RawValue field itself.
+func realm(t *testing.T, tag int, s string) asn1.RawValue {
+	inner, err := asn1.Marshal(generalString(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: inner}
+}
+
+func asn1Principal(p keytab.Principal) asn1PrincipalName {
+	n := asn1PrincipalName{NameType: p.NameType}
+	for _, c := range p.Components {
+		n.NameString = append(n.NameString, generalString(c))
+	}
+	return n
+}
+
+// makeTicket returns a DER Ticket for service, issued to client, holding
+// sessionKey and encrypted in serviceKey.
+func makeTicket(t *testing.T, client, service keytab.Principal,
+	serviceKey, sessionKey []byte, start, end time.Time) []byte {
+
+	encPart, err := asn1.MarshalWithParams(asn1EncTicketPart{
+		Flags:     asn1.BitString{Bytes: make([]byte, 4), BitLength: 32},
+		Key:       asn1EncryptionKey{KeyType: int32(enctype.ARCFOUR_HMAC), KeyValue: sessionKey},
+		CRealm:    realm(t, 2, client.Realm),
+		CName:     asn1Principal(client),
+		Transited: asn1TransitedEncoding{TRType: 1, Contents: []byte{}},
+		AuthTime:  start,
+		StartTime: start,
+		EndTime:   end,
+	}, "application,explicit,tag:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// key usage 2 is the ticket's encrypted part
+	ticket, err := asn1.MarshalWithParams(asn1Ticket{
+		TktVNO: 5,
+		Realm:  realm(t, 1, service.Realm),
+		SName:  asn1Principal(service),
+		EncPart: asn1EncryptedData{
+			EType:  int32(enctype.ARCFOUR_HMAC),
+			KVNO:   1,
+			Cipher: rc4HMACEncrypt(t, serviceKey, 2, encPart),
+		},
+	}, "application,explicit,tag:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticket
+}
+
+// rc4HMACEncrypt encrypts data for a key usage as in RFC 4757.
+func rc4HMACEncrypt(t *testing.T, key []byte, usage uint32, data []byte) []byte {
+	var salt [4]byte
+	binary.LittleEndian.PutUint32(salt[:], usage)
+	k1 := hmacMD5(key, salt[:])
+
+	plain := append(randomBytes(t, 8), data...)
+	checksum := hmacMD5(k1, plain)
+
+	c, err := rc4.NewCipher(hmacMD5(k1, checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(plain))
+	c.XORKeyStream(out, plain)
+
+	return append(checksum, out...)
+}
+
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// makeCcache returns a version 4 FILE credential cache for client holding
+// one ticket.
+func makeCcache(client, service keytab.Principal, sessionKey, ticket []byte,
+	start, end time.Time) []byte {
+
+	var b []byte
+	u16 := func(v int) { b = binary.BigEndian.AppendUint16(b, uint16(v)) }
+	u32 := func(v int) { b = binary.BigEndian.AppendUint32(b, uint32(v)) }
+	octets := func(d []byte) { u32(len(d)); b = append(b, d...) }
+	principal := func(p keytab.Principal) {
+		u32(int(p.NameType))
+		u32(len(p.Components))
+		octets([]byte(p.Realm))
+		for _, c := range p.Components {
+			octets([]byte(c))
+		}
+	}
+
+	u16(0x0504)
+	u16(0) // no header tags
+	principal(client)
+
+	principal(client)
+	principal(service)
+	u16(int(enctype.ARCFOUR_HMAC))
+	octets(sessionKey)
+	u32(int(start.Unix())) // authtime
+	u32(int(start.Unix())) // starttime
+	u32(int(end.Unix()))   // endtime
+	u32(0)                 // renew till
+	b = append(b, 0)       // is_skey
+	u32(0)                 // flags
+	u32(0)                 // addresses
+	u32(0)                 // authorization data
+	octets(ticket)
+	octets(nil) // second ticket
+
+	return b
+}
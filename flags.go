@@ -0,0 +1,150 @@
+package gssapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ContextFlags is a set of the GSS_C_*_FLAG context-level services, as
+// requested from and returned by InitSecContext and AcceptSecContext.
+type ContextFlags uint32
+
+// contextFlagNames names the flag bits, in bit order.
+var contextFlagNames = []struct {
+	flag ContextFlags
+	name string
+}{
+	{ContextFlags(GSS_C_DELEG_FLAG), "deleg"},
+	{GSS_C_MUTUAL_FLAG, "mutual"},
+	{GSS_C_REPLAY_FLAG, "replay"},
+	{GSS_C_SEQUENCE_FLAG, "sequence"},
+	{GSS_C_CONF_FLAG, "conf"},
+	{GSS_C_INTEG_FLAG, "integ"},
+	{GSS_C_ANON_FLAG, "anon"},
+	{GSS_C_PROT_READY_FLAG, "prot_ready"},
+	{GSS_C_TRANS_FLAG, "trans"},
+	{GSS_C_DCE_STYLE, "dce_style"},
+	{GSS_C_IDENTIFY_FLAG, "identify"},
+	{GSS_C_EXTENDED_ERROR_FLAG, "extended_error"},
+	{GSS_C_DELEG_POLICY_FLAG, "deleg_policy"},
+}
+
+// Has reports whether all of flags are set in f.
+func (f ContextFlags) Has(flags ContextFlags) bool {
+	return f&flags == flags
+}
+
+// Missing returns the flags in required that are not set in f.
+func (f ContextFlags) Missing(required ContextFlags) ContextFlags {
+	return required &^ f
+}
+
+// Names returns the names of the flags set in f, such as "mutual" for
+// GSS_C_MUTUAL_FLAG. Unknown bits are given in hexadecimal.
+func (f ContextFlags) Names() []string {
+	names := []string{}
+	for _, n := range contextFlagNames {
+		if f&n.flag != 0 {
+			names = append(names, n.name)
+			f &^= n.flag
+		}
+	}
+	for bit := ContextFlags(1); f != 0; bit <<= 1 {
+		if f&bit != 0 {
+			names = append(names, fmt.Sprintf("%#x", uint32(bit)))
+			f &^= bit
+		}
+	}
+	return names
+}
+
+// String returns the flag names joined by "|", or "none".
+func (f ContextFlags) String() string {
+	if f == 0 {
+		return "none"
+	}
+	return strings.Join(f.Names(), "|")
+}
+
+// MarshalJSON encodes the flags as an array of their names.
+func (f ContextFlags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Names())
+}
+
+// UnmarshalJSON decodes an array of flag names, as written by MarshalJSON,
+// or a plain number.
+func (f *ContextFlags) UnmarshalJSON(data []byte) error {
+	var n uint32
+	if json.Unmarshal(data, &n) == nil {
+		*f = ContextFlags(n)
+		return nil
+	}
+
+	var names []string
+	err := json.Unmarshal(data, &names)
+	if err != nil {
+		return err
+	}
+
+	var flags ContextFlags
+	for _, name := range names {
+		flag, err := parseContextFlag(name)
+		if err != nil {
+			return err
+		}
+		flags |= flag
+	}
+	*f = flags
+	return nil
+}
+
+func parseContextFlag(name string) (ContextFlags, error) {
+	for _, n := range contextFlagNames {
+		if n.name == name {
+			return n.flag, nil
+		}
+	}
+	var bit uint32
+	_, err := fmt.Sscanf(name, "0x%x", &bit)
+	if err != nil {
+		return 0, fmt.Errorf("unknown context flag %q", name)
+	}
+	return ContextFlags(bit), nil
+}
+
+// A MissingFlagsError is returned when an established context lacks services
+// that were required of it.
+type MissingFlagsError struct {
+	Required ContextFlags
+	Granted  ContextFlags
+}
+
+func (e *MissingFlagsError) Error() string {
+	return fmt.Sprintf("security context lacks required services %v (granted %v)",
+		e.Granted.Missing(e.Required), e.Granted)
+}
+
+// CheckFlags returns a *MissingFlagsError if retFlags, as returned once a
+// context is established, lacks any of required.
+func CheckFlags(retFlags uint32, required ContextFlags) error {
+	granted := ContextFlags(retFlags)
+	if !granted.Has(required) {
+		return &MissingFlagsError{Required: required, Granted: granted}
+	}
+	return nil
+}
+
+// CheckFlags returns a *MissingFlagsError if the established context lacks
+// any of required. Initiator and Acceptor make this check themselves; it is
+// for contexts established with InitSecContext or AcceptSecContext.
+func (ctx *CtxId) CheckFlags(required ContextFlags) error {
+	srcName, targetName, _, _, flags, _, _, err := ctx.InquireContext()
+	if err != nil {
+		return err
+	}
+	srcName.Release()
+	targetName.Release()
+
+	return CheckFlags(uint32(flags), required)
+}
@@ -0,0 +1,61 @@
+package gssapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCtxIdCheckFlags(t *testing.T) {
+	ini, acc := newTestContexts(t, uint32(GSS_C_MUTUAL_FLAG|GSS_C_INTEG_FLAG))
+	defer ini.DeleteSecContext()
+	defer acc.DeleteSecContext()
+
+	for _, ctx := range []*CtxId{ini, acc} {
+		err := ctx.CheckFlags(GSS_C_MUTUAL_FLAG | GSS_C_INTEG_FLAG)
+		if err != nil {
+			t.Errorf("CheckFlags(mutual|integ) = %v", err)
+		}
+
+		// there is no forwardable ticket to delegate
+		err = ctx.CheckFlags(GSS_C_MUTUAL_FLAG | ContextFlags(GSS_C_DELEG_FLAG))
+		var missing *MissingFlagsError
+		if !errors.As(err, &missing) {
+			t.Fatalf("CheckFlags(mutual|deleg) = %v, want a *MissingFlagsError", err)
+		}
+		if m := missing.Granted.Missing(missing.Required); m != ContextFlags(GSS_C_DELEG_FLAG) {
+			t.Errorf("missing %v, want deleg", m)
+		}
+	}
+}
+
+func TestRequiredFlags(t *testing.T) {
+	k := newTestKrb5(t)
+	iniCred, accCred := k.credentials(t)
+	defer iniCred.Release()
+	defer accCred.Release()
+
+	for _, tc := range []struct {
+		name                string
+		initiator, acceptor ContextFlags
+	}{
+		{"initiator", ContextFlags(GSS_C_DELEG_FLAG), 0},
+		{"acceptor", 0, ContextFlags(GSS_C_DELEG_FLAG)},
+	} {
+		ini, err := NewInitiator(iniCred, testService, GSS_KRB5_NT_PRINCIPAL_NAME,
+			GSS_MECH_KRB5, uint32(GSS_C_MUTUAL_FLAG))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ini.RequiredFlags = tc.initiator
+		acc := NewAcceptor(accCred)
+		acc.RequiredFlags = tc.acceptor
+
+		err = establish(t, ini, acc)
+		var missing *MissingFlagsError
+		if !errors.As(err, &missing) {
+			t.Errorf("%s: establish = %v, want a *MissingFlagsError", tc.name, err)
+		}
+		ini.Close()
+		acc.Close()
+	}
+}
@@ -0,0 +1,45 @@
+package gssapi
+
+import (
+	"time"
+)
+
+// InitSecContextAs starts a security context to targetName on behalf of
+// user, who need not have authenticated with Kerberos at all. It obtains
+// user's credentials with AcquireCredImpersonateName (S4U2Self) from
+// impersonatorCredHandle, which must be an initiator credential for the
+// calling service, and then calls InitSecContext with them (S4U2Proxy). The
+// KDC must allow the service to delegate to targetName.
+//
+// userCred is returned so that further InitSecContext iterations, after
+// ErrContinueNeeded, can be made with it. userCred, ctxOut, actualMechType
+// and outputToken must be .Release()-ed by the caller
+func InitSecContextAs(impersonatorCredHandle *CredId, user *Name,
+	targetName *Name, reqFlags uint32, timeReq time.Duration,
+	inputChanBindings ChannelBindings) (
+	userCred *CredId, ctxOut *CtxId, actualMechType *OID, outputToken *Buffer,
+	retFlags uint32, timeRec time.Duration, err error) {
+
+	mechs, err := MakeOIDSet(GSS_MECH_KRB5)
+	if err != nil {
+		return nil, nil, nil, nil, 0, 0, err
+	}
+	defer mechs.Release()
+
+	userCred, actualMechs, _, err := AcquireCredImpersonateName(
+		impersonatorCredHandle, user, timeReq, mechs, GSS_C_INITIATE)
+	if err != nil {
+		return nil, nil, nil, nil, 0, 0, err
+	}
+	actualMechs.Release()
+
+	ctxOut, actualMechType, outputToken, retFlags, timeRec, err = InitSecContext(
+		userCred, GSS_C_NO_CONTEXT, targetName, GSS_MECH_KRB5, reqFlags,
+		timeReq, inputChanBindings, GSS_C_NO_BUFFER)
+	if err != nil && err != ErrContinueNeeded {
+		userCred.Release()
+		return nil, nil, nil, nil, 0, 0, err
+	}
+
+	return userCred, ctxOut, actualMechType, outputToken, retFlags, timeRec, err
+}
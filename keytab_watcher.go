@@ -0,0 +1,258 @@
+package gssapi
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// defaultKeytabPollInterval is how often a KeytabCredHolder looks at its
+// keytab unless PollInterval says otherwise.
+const defaultKeytabPollInterval = 30 * time.Second
+
+// A KeytabCredHolder holds an acceptor credential for a keytab file and
+// re-acquires it when the file changes, so that rotated service keys are
+// picked up without restarting. The credential is swapped atomically: callers
+// use it through Do, and the old handle is only released once no Do call is
+// using it.
+//
+// Changes are detected by polling the file's identity, size and modification
+// time, which also catches a keytab replaced by rename. A keytab that cannot
+// be parsed, for instance because it is being rewritten in place, is retried
+// on the next poll while the previous credential stays in use.
+type KeytabCredHolder struct {
+	// OnReload, if set, is called after the credential has been
+	// re-acquired, with the key versions now loaded.
+	OnReload func(kvnos map[string][]uint32)
+
+	// OnFailure, if set, is called when reloading the keytab fails.
+	OnFailure func(err error)
+
+	// PollInterval is how often the keytab is checked for changes. It
+	// defaults to 30 seconds.
+	PollInterval time.Duration
+
+	ktname string
+	path   string
+	creds  *CredManager
+
+	reloadMu sync.Mutex
+	mu       sync.Mutex
+	info     os.FileInfo
+	kvnos    map[string][]uint32
+	closed   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewKeytabCredHolder acquires an acceptor credential for desiredName from
+// the FILE keytab ktname. desiredName may be GSS_C_NO_NAME() to accept for any
+// principal in the keytab; otherwise it must stay valid while the holder is
+// in use.
+func NewKeytabCredHolder(desiredName *Name, ktname string) (*KeytabCredHolder, error) {
+	if !isFileKeytab(ktname) {
+		return nil, fmt.Errorf("keytab %s: only FILE keytabs can be watched", ktname)
+	}
+
+	h := &KeytabCredHolder{
+		PollInterval: defaultKeytabPollInterval,
+		ktname:       ktname,
+		path:         keytab.Path(ktname),
+	}
+
+	info, kvnos, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+
+	store := CredStore{CredStoreKeytab: ktname}
+	creds, err := NewCredManager(func() (*CredId, error) {
+		cred, actualMechs, _, err := AcquireCredFrom(desiredName, 0,
+			GSS_C_NO_OID_SET, GSS_C_ACCEPT, store)
+		if err != nil {
+			return nil, err
+		}
+		actualMechs.Release()
+		return cred, nil
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	h.creds = creds
+	h.info = info
+	h.kvnos = kvnos
+
+	return h, nil
+}
+
+// Start starts watching the keytab in the background. Set the callbacks and
+// PollInterval before calling it.
+func (h *KeytabCredHolder) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || h.stop != nil {
+		return
+	}
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+	go h.run(h.stop, h.done)
+}
+
+// Do calls fn with the current acceptor credential. The credential must not
+// be used or retained after fn returns.
+func (h *KeytabCredHolder) Do(fn func(cred *CredId) error) error {
+	return h.creds.Do(fn)
+}
+
+// KVNOs returns the key versions in the keytab the current credential was
+// acquired from, sorted, by principal.
+func (h *KeytabCredHolder) KVNOs() map[string][]uint32 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kvnos := make(map[string][]uint32, len(h.kvnos))
+	for p, v := range h.kvnos {
+		kvnos[p] = append([]uint32(nil), v...)
+	}
+	return kvnos
+}
+
+// Reload re-acquires the credential if the keytab has changed since it was
+// last loaded, and reports whether it did. It can be called directly, for
+// instance on SIGHUP, whether or not Start has been called.
+func (h *KeytabCredHolder) Reload() (bool, error) {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return false, h.failed(err)
+	}
+
+	h.mu.Lock()
+	unchanged := sameFileVersion(h.info, info)
+	h.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	info, kvnos, err := h.load()
+	if err != nil {
+		return false, h.failed(err)
+	}
+
+	err = h.creds.Renew()
+	if err != nil {
+		return false, h.failed(err)
+	}
+
+	h.mu.Lock()
+	h.info = info
+	h.kvnos = kvnos
+	h.mu.Unlock()
+
+	if h.OnReload != nil {
+		h.OnReload(h.KVNOs())
+	}
+	return true, nil
+}
+
+// Close stops watching the keytab and releases the credential. It waits for
+// calls to Do in progress to return.
+func (h *KeytabCredHolder) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	stop, done := h.stop, h.done
+	h.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	return h.creds.Close()
+}
+
+// load parses the keytab and returns the file info it was read under and
+// the key versions it holds. The file is stat'ed before reading, so that a
+// change made while reading is seen again on the next poll.
+func (h *KeytabCredHolder) load() (os.FileInfo, map[string][]uint32, error) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kt, err := keytab.Load(h.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("keytab %s: %v", h.ktname, err)
+	}
+	if len(kt.Entries) == 0 {
+		return nil, nil, fmt.Errorf("keytab %s: no entries", h.ktname)
+	}
+
+	kvnos := make(map[string][]uint32)
+	for _, e := range kt.Entries {
+		p := e.Principal.String()
+		if !containsKVNO(kvnos[p], e.KVNO) {
+			kvnos[p] = append(kvnos[p], e.KVNO)
+		}
+	}
+	for _, v := range kvnos {
+		sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+	}
+
+	return info, kvnos, nil
+}
+
+func (h *KeytabCredHolder) failed(err error) error {
+	if h.OnFailure != nil {
+		h.OnFailure(err)
+	}
+	return err
+}
+
+func (h *KeytabCredHolder) run(stop, done chan struct{}) {
+	defer close(done)
+
+	d := h.PollInterval
+	if d <= 0 {
+		d = defaultKeytabPollInterval
+	}
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			h.Reload()
+		}
+	}
+}
+
+// sameFileVersion reports whether b is the same file as a, unmodified.
+func sameFileVersion(a, b os.FileInfo) bool {
+	return a != nil && os.SameFile(a, b) && a.Size() == b.Size() &&
+		a.ModTime().Equal(b.ModTime())
+}
+
+func containsKVNO(kvnos []uint32, kvno uint32) bool {
+	for _, v := range kvnos {
+		if v == kvno {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package krb5
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_krb5.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+
+	gssapi "github.com/lixiangyun/go-gssapi"
+)
+
+// SetCcacheName implements gss_krb5_ccache_name, setting the default
+// credential cache used by the krb5 mechanism, and returns the previous
+// name. MIT keeps this setting per OS thread, so the caller must hold
+// runtime.LockOSThread for as long as it relies on it; WithCcache does that.
+func SetCcacheName(name string) (old string, err error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	cold, err := setCcacheName(cname)
+	if err != nil {
+		return "", err
+	}
+
+	if cold != nil {
+		old = C.GoString(cold)
+		C.free(unsafe.Pointer(cold))
+	}
+	return old, nil
+}
+
+// WithCcache calls fn with ccache as the default credential cache of the
+// krb5 mechanism. fn runs on a locked OS thread, so that gssapi calls made
+// from it, such as AcquireCred with GSS_C_NO_NAME, see the setting; the
+// previous setting is restored afterwards, including having none.
+func WithCcache(ccache string, fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cname := C.CString(ccache)
+	defer C.free(unsafe.Pointer(cname))
+
+	old, err := setCcacheName(cname)
+	if err != nil {
+		return err
+	}
+	if old != nil && isDefaultCcacheName(old) {
+		// MIT reports the library default when nothing was set; restoring
+		// NULL rather than that name keeps following KRB5CCNAME
+		C.free(unsafe.Pointer(old))
+		old = nil
+	}
+	defer func() {
+		restored, _ := setCcacheName(old)
+		C.free(unsafe.Pointer(restored))
+		C.free(unsafe.Pointer(old))
+	}()
+
+	return fn()
+}
+
+// setCcacheName calls gss_krb5_ccache_name and returns a malloc-ed copy of
+// the previous name, or nil if there was none. The library's own pointer is
+// only valid until the next call, so it is copied.
+func setCcacheName(name *C.char) (*C.char, error) {
+	var out *C.char
+	min := C.OM_uint32(0)
+	maj := C.gss_krb5_ccache_name(&min, name, &out)
+	err := gssStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil {
+		return nil, nil
+	}
+	return C.strdup(out), nil
+}
+
+// isDefaultCcacheName reports whether name is the default credential cache
+// a fresh krb5 context would use.
+func isDefaultCcacheName(name *C.char) bool {
+	var def bool
+	withContext(func(ctx C.krb5_context) error {
+		cdef := C.krb5_cc_default_name(ctx)
+		def = cdef != nil && C.strcmp(cdef, name) == 0
+		return nil
+	})
+	return def
+}
+
+// CopyCcache implements gss_krb5_copy_ccache. It initializes the credential
+// cache ccache (for instance "FILE:/tmp/krb5cc_app") for the credential's
+// principal and copies the credential's tickets into it.
+func CopyCcache(cred *gssapi.CredId, ccache string) error {
+	name, _, _, mechs, err := gssapi.InquireCred(cred)
+	if err != nil {
+		return err
+	}
+	defer name.Release()
+	mechs.Release()
+
+	principal, oid, err := name.Display()
+	if err != nil {
+		return err
+	}
+	oid.Release()
+
+	return withContext(func(ctx C.krb5_context) error {
+		cprinc := C.CString(principal)
+		defer C.free(unsafe.Pointer(cprinc))
+
+		var princ C.krb5_principal
+		code := C.krb5_parse_name(ctx, cprinc, &princ)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		defer C.krb5_free_principal(ctx, princ)
+
+		ccname := C.CString(ccache)
+		defer C.free(unsafe.Pointer(ccname))
+
+		var cc C.krb5_ccache
+		code = C.krb5_cc_resolve(ctx, ccname, &cc)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		defer C.krb5_cc_close(ctx, cc)
+
+		code = C.krb5_cc_initialize(ctx, cc, princ)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+
+		min := C.OM_uint32(0)
+		maj := C.gss_krb5_copy_ccache(&min, credHandle(cred), cc)
+		return gssStatus(maj, min)
+	})
+}
+
+// DestroyCcache implements krb5_cc_destroy, removing the credential cache
+// ccache and the tickets in it. It frees a MEMORY cache returned by
+// gssapi.CredId.CcacheName.
+func DestroyCcache(ccache string) error {
+	return withContext(func(ctx C.krb5_context) error {
+		ccname := C.CString(ccache)
+		defer C.free(unsafe.Pointer(ccname))
+
+		var cc C.krb5_ccache
+		code := C.krb5_cc_resolve(ctx, ccname, &cc)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+
+		// krb5_cc_destroy closes the handle as well
+		code = C.krb5_cc_destroy(ctx, cc)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		return nil
+	})
+}
+
+// ImportCred implements gss_krb5_import_cred, building a credential from an
+// existing credential cache and/or keytab. Any of ccache, keytabPrincipal
+// and keytab may be empty: with only ccache the result is an initiator
+// credential, with keytab an acceptor credential, restricted to
+// keytabPrincipal if that is given. The credential must be .Release()-ed by
+// the caller
+func ImportCred(ccache string, keytabPrincipal string, keytab string) (
+	*gssapi.CredId, error) {
+
+	cred := gssapi.NewCredId()
+
+	err := withContext(func(ctx C.krb5_context) error {
+		var cc C.krb5_ccache
+		if ccache != "" {
+			ccname := C.CString(ccache)
+			defer C.free(unsafe.Pointer(ccname))
+
+			code := C.krb5_cc_resolve(ctx, ccname, &cc)
+			if code != 0 {
+				return krb5Error(ctx, code)
+			}
+			defer C.krb5_cc_close(ctx, cc)
+		}
+
+		var princ C.krb5_principal
+		if keytabPrincipal != "" {
+			cprinc := C.CString(keytabPrincipal)
+			defer C.free(unsafe.Pointer(cprinc))
+
+			code := C.krb5_parse_name(ctx, cprinc, &princ)
+			if code != 0 {
+				return krb5Error(ctx, code)
+			}
+			defer C.krb5_free_principal(ctx, princ)
+		}
+
+		var kt C.krb5_keytab
+		if keytab != "" {
+			ktname := C.CString(keytab)
+			defer C.free(unsafe.Pointer(ktname))
+
+			code := C.krb5_kt_resolve(ctx, ktname, &kt)
+			if code != 0 {
+				return krb5Error(ctx, code)
+			}
+			defer C.krb5_kt_close(ctx, kt)
+		}
+
+		min := C.OM_uint32(0)
+		maj := C.gss_krb5_import_cred(&min, cc, princ, kt, credHandlePtr(cred))
+		return gssStatus(maj, min)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// RegisterAcceptorIdentity implements krb5_gss_register_acceptor_identity,
+// making keytab the default keytab for acceptor credentials in this process
+// without setting KRB5_KTNAME.
+func RegisterAcceptorIdentity(keytab string) error {
+	ktname := C.CString(keytab)
+	defer C.free(unsafe.Pointer(ktname))
+
+	maj := C.krb5_gss_register_acceptor_identity(ktname)
+	return gssStatus(maj, 0)
+}
@@ -0,0 +1,459 @@
+/*
+Package ccache reads MIT Kerberos FILE credential caches (format versions 3
+and 4) and DIR cache collections in pure Go, as klist does, without cgo or the
+GSSAPI library state.
+
+It is meant for diagnostics, for instance to explain a GSS_S_NO_CRED or
+GSS_S_CREDENTIALS_EXPIRED from AcquireCred by showing what the cache actually
+holds.
+*/
+package ccache
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// Credential cache format versions.
+const (
+	Version3 = 0x0503
+	Version4 = 0x0504
+)
+
+// The realm of the configuration entries MIT stores as credentials.
+const configRealm = "X-CACHECONF:"
+
+// ErrFormat is returned when a credential cache cannot be parsed.
+var ErrFormat = errors.New("malformed credential cache")
+
+// ErrNoCredentials is returned by Check for a cache without tickets.
+var ErrNoCredentials = errors.New("no credentials in cache")
+
+// Ticket flags, as in RFC 4120 section 5.3.
+const (
+	FlagForwardable          Flags = 0x40000000
+	FlagForwarded            Flags = 0x20000000
+	FlagProxiable            Flags = 0x10000000
+	FlagProxy                Flags = 0x08000000
+	FlagMayPostdate          Flags = 0x04000000
+	FlagPostdated            Flags = 0x02000000
+	FlagInvalid              Flags = 0x01000000
+	FlagRenewable            Flags = 0x00800000
+	FlagInitial              Flags = 0x00400000
+	FlagPreAuthent           Flags = 0x00200000
+	FlagHWAuthent            Flags = 0x00100000
+	FlagTransitPolicyChecked Flags = 0x00080000
+	FlagOKAsDelegate         Flags = 0x00040000
+	FlagAnonymous            Flags = 0x00008000
+)
+
+// Flags are the ticket flags of a credential.
+type Flags uint32
+
+var flagLetters = []struct {
+	flag   Flags
+	letter byte
+}{
+	{FlagForwardable, 'F'},
+	{FlagForwarded, 'f'},
+	{FlagProxiable, 'P'},
+	{FlagProxy, 'p'},
+	{FlagMayPostdate, 'D'},
+	{FlagPostdated, 'd'},
+	{FlagInvalid, 'i'},
+	{FlagRenewable, 'R'},
+	{FlagInitial, 'I'},
+	{FlagHWAuthent, 'H'},
+	{FlagPreAuthent, 'A'},
+	{FlagTransitPolicyChecked, 'T'},
+	{FlagOKAsDelegate, 'O'},
+	{FlagAnonymous, 'a'},
+}
+
+// Has reports whether all of the flags in f2 are set.
+func (f Flags) Has(f2 Flags) bool {
+	return f&f2 == f2
+}
+
+// String returns the flags as klist -f shows them, for instance "FRIA".
+func (f Flags) String() string {
+	var b []byte
+	for _, fl := range flagLetters {
+		if f.Has(fl.flag) {
+			b = append(b, fl.letter)
+		}
+	}
+	return string(b)
+}
+
+// A Credential is one ticket in a cache.
+type Credential struct {
+	Client        keytab.Principal
+	Server        keytab.Principal
+	KeyEnctype    enctype.Enctype // of the session key
+	TicketEnctype enctype.Enctype // of the ticket, 0 if it cannot be decoded
+	AuthTime      time.Time
+	StartTime     time.Time
+	EndTime       time.Time
+	RenewTill     time.Time
+	IsSKey        bool
+	Flags         Flags
+	Ticket        []byte
+}
+
+// IsConfig reports whether the credential is really a cache configuration
+// entry, which klist does not show.
+func (c Credential) IsConfig() bool {
+	return c.Server.Realm == configRealm
+}
+
+// IsTGT reports whether the credential is a ticket-granting ticket.
+func (c Credential) IsTGT() bool {
+	return len(c.Server.Components) == 2 && c.Server.Components[0] == "krbtgt"
+}
+
+// Expired reports whether the ticket has expired at now.
+func (c Credential) Expired(now time.Time) bool {
+	return !now.Before(c.EndTime)
+}
+
+// String describes the credential in the style of klist.
+func (c Credential) String() string {
+	const layout = "01/02/06 15:04:05"
+	s := fmt.Sprintf("%s  %s  %s", c.StartTime.Format(layout),
+		c.EndTime.Format(layout), c.Server)
+	if c.Flags.Has(FlagRenewable) {
+		s += fmt.Sprintf("\n\trenew until %s", c.RenewTill.Format(layout))
+	}
+	s += fmt.Sprintf("\n\tFlags: %s, Etype (skey, tkt): %s, %s",
+		c.Flags, c.KeyEnctype, c.TicketEnctype)
+	return s
+}
+
+// A Cache is the contents of a credential cache.
+type Cache struct {
+	Name        string
+	Version     int
+	KDCOffset   time.Duration
+	Principal   keytab.Principal
+	Credentials []Credential
+}
+
+// DefaultName returns the default credential cache name: KRB5CCNAME if set,
+// otherwise the usual FILE cache for the current user.
+func DefaultName() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return name
+	}
+	return fmt.Sprintf("FILE:/tmp/krb5cc_%d", os.Getuid())
+}
+
+// Load reads a credential cache. name may be a plain path, "FILE:path",
+// "DIR::path" for one cache of a collection, or "DIR:dir" for the primary
+// cache of a collection. Other cache types live outside the file system and
+// are not supported.
+func Load(name string) (*Cache, error) {
+	var path string
+	switch {
+	case strings.HasPrefix(name, "FILE:"):
+		path = name[len("FILE:"):]
+	case strings.HasPrefix(name, "DIR::"):
+		path = name[len("DIR::"):]
+	case strings.HasPrefix(name, "DIR:"):
+		primary, err := Primary(name[len("DIR:"):])
+		if err != nil {
+			return nil, err
+		}
+		path = primary
+	case strings.Contains(name, ":") && !strings.HasPrefix(name, "/"):
+		return nil, fmt.Errorf("unsupported credential cache type: %s", name)
+	default:
+		path = name
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	c.Name = "FILE:" + path
+	return c, nil
+}
+
+// Primary returns the path of the primary cache of a DIR collection, as
+// named by its "primary" file, or dir/tkt if there is none.
+func Primary(dir string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "primary"))
+	if os.IsNotExist(err) {
+		return filepath.Join(dir, "tkt"), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, strings.TrimSpace(string(data))), nil
+}
+
+// LoadCollection reads all the caches of a DIR collection, "DIR:dir" or just
+// dir, with the primary cache first.
+func LoadCollection(dir string) ([]*Cache, error) {
+	dir = strings.TrimPrefix(dir, "DIR:")
+
+	primary, err := Primary(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "tkt*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i] == primary && files[j] != primary
+	})
+
+	caches := make([]*Cache, 0, len(files))
+	for _, f := range files {
+		c, err := Load("DIR::" + f)
+		if err != nil {
+			return nil, err
+		}
+		c.Name = "DIR::" + f
+		caches = append(caches, c)
+	}
+	return caches, nil
+}
+
+// Tickets returns the credentials that are real tickets, skipping
+// configuration entries.
+func (c *Cache) Tickets() []Credential {
+	var tickets []Credential
+	for _, cred := range c.Credentials {
+		if !cred.IsConfig() {
+			tickets = append(tickets, cred)
+		}
+	}
+	return tickets
+}
+
+// TGT returns the ticket-granting ticket for the default principal's realm.
+func (c *Cache) TGT() (Credential, bool) {
+	for _, cred := range c.Tickets() {
+		if cred.IsTGT() && cred.Server.Components[1] == c.Principal.Realm {
+			return cred, true
+		}
+	}
+	return Credential{}, false
+}
+
+// Check explains why the cache may not be usable at now: it has no tickets,
+// or its TGT (or, without one, all its tickets) has expired.
+func (c *Cache) Check(now time.Time) error {
+	tickets := c.Tickets()
+	if len(tickets) == 0 {
+		return fmt.Errorf("%s: %w for %s", c.Name, ErrNoCredentials, c.Principal)
+	}
+
+	if tgt, ok := c.TGT(); ok {
+		if tgt.Expired(now) {
+			return fmt.Errorf("%s: ticket-granting ticket for %s expired at %s",
+				c.Name, c.Principal, tgt.EndTime)
+		}
+		return nil
+	}
+
+	for _, t := range tickets {
+		if !t.Expired(now) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: all tickets for %s have expired", c.Name, c.Principal)
+}
+
+// Parse parses the contents of a FILE credential cache.
+func Parse(data []byte) (*Cache, error) {
+	r := &reader{data: data}
+
+	c := &Cache{Version: int(r.uint16())}
+	if c.Version != Version3 && c.Version != Version4 {
+		return nil, fmt.Errorf("%w: unsupported version %#x", ErrFormat, c.Version)
+	}
+
+	if c.Version == Version4 {
+		hdr := &reader{data: r.bytes(int(r.uint16()))}
+		for r.err == nil && hdr.err == nil && len(hdr.data) > 0 {
+			tag := hdr.uint16()
+			field := &reader{data: hdr.bytes(int(hdr.uint16()))}
+			if tag == 1 {
+				// KDC time offset
+				sec := int32(field.uint32())
+				usec := int32(field.uint32())
+				c.KDCOffset = time.Duration(sec)*time.Second +
+					time.Duration(usec)*time.Microsecond
+			}
+		}
+		if hdr.err != nil {
+			return nil, hdr.err
+		}
+	}
+
+	c.Principal = r.principal()
+	for r.err == nil && len(r.data) > 0 {
+		cred := r.credential(c.Version)
+		if r.err == nil {
+			c.Credentials = append(c.Credentials, cred)
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c, nil
+}
+
+// reader decodes big-endian fields.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = fmt.Errorf("%w: truncated field", ErrFormat)
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) octets() []byte {
+	return r.bytes(int(r.uint32()))
+}
+
+// skipList skips a counted list of typed octet strings, as used for
+// addresses and authorization data.
+func (r *reader) skipList() {
+	n := r.uint32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		r.uint16()
+		r.octets()
+	}
+}
+
+func (r *reader) time() time.Time {
+	t := r.uint32()
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(t), 0)
+}
+
+func (r *reader) principal() keytab.Principal {
+	p := keytab.Principal{NameType: int32(r.uint32())}
+	n := int(r.uint32())
+	p.Realm = string(r.octets())
+	for i := 0; i < n && r.err == nil; i++ {
+		p.Components = append(p.Components, string(r.octets()))
+	}
+	return p
+}
+
+func (r *reader) credential(version int) Credential {
+	c := Credential{
+		Client: r.principal(),
+		Server: r.principal(),
+	}
+
+	c.KeyEnctype = enctype.Enctype(r.uint16())
+	if version == Version3 {
+		// version 3 repeats the enctype
+		r.uint16()
+	}
+	r.octets() // the session key itself is not exposed
+
+	c.AuthTime = r.time()
+	c.StartTime = r.time()
+	c.EndTime = r.time()
+	c.RenewTill = r.time()
+	c.IsSKey = r.uint8() != 0
+	c.Flags = Flags(r.uint32())
+
+	r.skipList() // addresses
+	r.skipList() // authorization data
+
+	c.Ticket = append([]byte(nil), r.octets()...)
+	r.octets() // second ticket
+
+	if c.StartTime.IsZero() {
+		c.StartTime = c.AuthTime
+	}
+	if !c.IsConfig() {
+		c.TicketEnctype = ticketEnctype(c.Ticket)
+	}
+	return c
+}
+
+// asn1Ticket is the Ticket of RFC 4120, enough of it to get the enctype.
+type asn1Ticket struct {
+	TktVNO  int               `asn1:"explicit,tag:0"`
+	Realm   asn1.RawValue     `asn1:"explicit,tag:1"`
+	SName   asn1.RawValue     `asn1:"explicit,tag:2"`
+	EncPart asn1EncryptedData `asn1:"explicit,tag:3"`
+}
+
+type asn1EncryptedData struct {
+	EType  int32  `asn1:"explicit,tag:0"`
+	KVNO   int    `asn1:"optional,explicit,tag:1"`
+	Cipher []byte `asn1:"explicit,tag:2"`
+}
+
+func ticketEnctype(ticket []byte) enctype.Enctype {
+	var t asn1Ticket
+	_, err := asn1.UnmarshalWithParams(ticket, &t, "application,explicit,tag:1")
+	if err != nil {
+		return 0
+	}
+	return enctype.Enctype(t.EncPart.EType)
+}
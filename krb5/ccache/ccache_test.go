@@ -0,0 +1,287 @@
+package ccache
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+var (
+	testStart = time.Unix(1700000000, 0)
+	testEnd   = testStart.Add(10 * time.Hour)
+	testRenew = testStart.Add(7 * 24 * time.Hour)
+)
+
+// builder writes a FILE credential cache field by field.
+type builder struct {
+	b []byte
+}
+
+func (b *builder) u8(v int)  { b.b = append(b.b, byte(v)) }
+func (b *builder) u16(v int) { b.b = binary.BigEndian.AppendUint16(b.b, uint16(v)) }
+func (b *builder) u32(v int) { b.b = binary.BigEndian.AppendUint32(b.b, uint32(v)) }
+
+func (b *builder) octets(d []byte) {
+	b.u32(len(d))
+	b.b = append(b.b, d...)
+}
+
+func (b *builder) principal(s string) {
+	p, err := keytab.ParsePrincipal(s)
+	if err != nil {
+		panic(err)
+	}
+	b.u32(int(p.NameType))
+	b.u32(len(p.Components))
+	b.octets([]byte(p.Realm))
+	for _, c := range p.Components {
+		b.octets([]byte(c))
+	}
+}
+
+func (b *builder) credential(version int, client, server string, e enctype.Enctype,
+	flags Flags, ticket []byte) {
+
+	b.principal(client)
+	b.principal(server)
+	b.u16(int(e))
+	if version == Version3 {
+		b.u16(int(e))
+	}
+	b.octets(make([]byte, 32))
+	b.u32(int(testStart.Unix())) // authtime
+	b.u32(0)                     // starttime, taken from authtime
+	b.u32(int(testEnd.Unix()))
+	b.u32(int(testRenew.Unix()))
+	b.u8(0) // is_skey
+	b.u32(int(flags))
+	b.u32(1) // one address
+	b.u16(2)
+	b.octets([]byte{192, 0, 2, 1})
+	b.u32(0) // no authorization data
+	b.octets(ticket)
+	b.octets(nil)
+}
+
+// ticket returns a DER Ticket whose encrypted part uses e.
+func ticket(t *testing.T, e enctype.Enctype) []byte {
+	t.Helper()
+
+	explicit := func(tag int, v interface{}) asn1.RawValue {
+		inner, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		full, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific,
+			Tag: tag, IsCompound: true, Bytes: inner})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{FullBytes: full}
+	}
+	sname := struct {
+		NameType int32    `asn1:"explicit,tag:0"`
+		Names    []string `asn1:"explicit,tag:1,general"`
+	}{2, []string{"krbtgt", "EXAMPLE.COM"}}
+
+	der, err := asn1.MarshalWithParams(asn1Ticket{
+		TktVNO:  5,
+		Realm:   explicit(1, asn1.RawValue{Tag: 27, Bytes: []byte("EXAMPLE.COM")}),
+		SName:   explicit(2, sname),
+		EncPart: asn1EncryptedData{EType: int32(e), KVNO: 2, Cipher: []byte{1, 2, 3}},
+	}, "application,explicit,tag:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestParseVersion3(t *testing.T) {
+	var b builder
+	b.u16(Version3)
+	b.principal("alice@EXAMPLE.COM")
+	b.credential(Version3, "alice@EXAMPLE.COM", "krbtgt/EXAMPLE.COM@EXAMPLE.COM",
+		enctype.AES256_CTS_HMAC_SHA1_96, FlagForwardable|FlagRenewable|FlagInitial,
+		ticket(t, enctype.AES256_CTS_HMAC_SHA1_96))
+
+	c, err := Parse(b.b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != Version3 {
+		t.Errorf("Version = %#x, want %#x", c.Version, Version3)
+	}
+	if got := c.Principal.String(); got != "alice@EXAMPLE.COM" {
+		t.Errorf("Principal = %s", got)
+	}
+	if len(c.Credentials) != 1 {
+		t.Fatalf("%d credentials, want 1", len(c.Credentials))
+	}
+
+	cred := c.Credentials[0]
+	if cred.KeyEnctype != enctype.AES256_CTS_HMAC_SHA1_96 ||
+		cred.TicketEnctype != enctype.AES256_CTS_HMAC_SHA1_96 {
+		t.Errorf("enctypes = %v, %v", cred.KeyEnctype, cred.TicketEnctype)
+	}
+	if !cred.StartTime.Equal(testStart) || !cred.EndTime.Equal(testEnd) ||
+		!cred.RenewTill.Equal(testRenew) {
+		t.Errorf("times = %v, %v, %v", cred.StartTime, cred.EndTime, cred.RenewTill)
+	}
+	if got := cred.Flags.String(); got != "FRI" {
+		t.Errorf("Flags = %s, want FRI", got)
+	}
+	if _, ok := c.TGT(); !ok {
+		t.Error("no TGT found")
+	}
+	if err := c.Check(testStart.Add(time.Hour)); err != nil {
+		t.Errorf("Check before expiry = %v", err)
+	}
+	if err := c.Check(testEnd); err == nil {
+		t.Error("Check after expiry succeeded")
+	}
+}
+
+func TestParseVersion4HeaderTags(t *testing.T) {
+	var b builder
+	b.u16(Version4)
+	b.u16(12 + 6) // header length
+	b.u16(1)      // KDC time offset
+	b.u16(8)
+	b.u32(-5)
+	b.u32(250000)
+	b.u16(99) // unknown tag, skipped
+	b.u16(2)
+	b.u16(0)
+	b.principal("alice@EXAMPLE.COM")
+	b.credential(Version4, "alice@EXAMPLE.COM", "pa_type@X-CACHECONF:", 0, 0,
+		[]byte("2"))
+	b.credential(Version4, "alice@EXAMPLE.COM", "HTTP/www.example.com@EXAMPLE.COM",
+		enctype.AES128_CTS_HMAC_SHA1_96, FlagForwardable, ticket(t, enctype.AES256_CTS_HMAC_SHA1_96))
+
+	c, err := Parse(b.b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := -5*time.Second + 250*time.Millisecond; c.KDCOffset != want {
+		t.Errorf("KDCOffset = %v, want %v", c.KDCOffset, want)
+	}
+	if len(c.Credentials) != 2 {
+		t.Fatalf("%d credentials, want 2", len(c.Credentials))
+	}
+	if !c.Credentials[0].IsConfig() {
+		t.Error("configuration entry not recognized")
+	}
+
+	tickets := c.Tickets()
+	if len(tickets) != 1 {
+		t.Fatalf("%d tickets, want 1", len(tickets))
+	}
+	if tickets[0].KeyEnctype != enctype.AES128_CTS_HMAC_SHA1_96 ||
+		tickets[0].TicketEnctype != enctype.AES256_CTS_HMAC_SHA1_96 {
+		t.Errorf("enctypes = %v, %v", tickets[0].KeyEnctype, tickets[0].TicketEnctype)
+	}
+	if _, ok := c.TGT(); ok {
+		t.Error("TGT found in a cache without one")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	var b builder
+	b.u16(Version4)
+	b.u16(12)
+	b.u16(1)
+	b.u16(8)
+	b.u32(0)
+	b.u32(0)
+	b.principal("alice@EXAMPLE.COM")
+	complete := map[int]bool{len(b.b): true}
+	b.credential(Version4, "alice@EXAMPLE.COM", "krbtgt/EXAMPLE.COM@EXAMPLE.COM",
+		enctype.AES256_CTS_HMAC_SHA1_96, 0, ticket(t, enctype.AES256_CTS_HMAC_SHA1_96))
+	complete[len(b.b)] = true
+
+	for n := 0; n < len(b.b); n++ {
+		_, err := Parse(b.b[:n])
+		if complete[n] {
+			if err != nil {
+				t.Errorf("Parse of %d bytes = %v", n, err)
+			}
+		} else if !errors.Is(err, ErrFormat) {
+			t.Errorf("Parse of %d bytes = %v, want %v", n, err, ErrFormat)
+		}
+	}
+
+	_, err := Parse([]byte{5, 2, 0, 0})
+	if !errors.Is(err, ErrFormat) {
+		t.Errorf("Parse of a version 0x502 cache = %v, want %v", err, ErrFormat)
+	}
+}
+
+func writeCache(t *testing.T, path, principal string) {
+	t.Helper()
+	var b builder
+	b.u16(Version4)
+	b.u16(0)
+	b.principal(principal)
+	err := ioutil.WriteFile(path, b.b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDIR(t *testing.T) {
+	dir := t.TempDir()
+	writeCache(t, filepath.Join(dir, "tkt"), "alice@EXAMPLE.COM")
+	writeCache(t, filepath.Join(dir, "tktbob"), "bob@EXAMPLE.COM")
+
+	// without a primary file, the primary cache is tkt
+	c, err := Load("DIR:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Principal.String(); got != "alice@EXAMPLE.COM" {
+		t.Errorf("primary without a primary file is %s", got)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "primary"), []byte("tktbob\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load("DIR:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Principal.String(); got != "bob@EXAMPLE.COM" {
+		t.Errorf("primary is %s, want bob@EXAMPLE.COM", got)
+	}
+	if want := "FILE:" + filepath.Join(dir, "tktbob"); c.Name != want {
+		t.Errorf("Name = %s, want %s", c.Name, want)
+	}
+
+	c, err = Load("DIR::" + filepath.Join(dir, "tkt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Principal.String(); got != "alice@EXAMPLE.COM" {
+		t.Errorf("DIR::.../tkt holds %s", got)
+	}
+
+	caches, err := LoadCollection("DIR:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(caches) != 2 || caches[0].Principal.String() != "bob@EXAMPLE.COM" {
+		t.Errorf("LoadCollection did not return the primary cache first: %v", caches)
+	}
+
+	_, err = Load("KEYRING:persistent:1000")
+	if err == nil {
+		t.Error("Load of a KEYRING cache succeeded")
+	}
+}
@@ -0,0 +1,32 @@
+package krb5
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDestroyCcache(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "ccache")
+
+	// a version 4 cache for alice@EXAMPLE.COM without tickets
+	data := []byte{
+		0x05, 0x04, 0, 0,
+		0, 0, 0, 1, 0, 0, 0, 1,
+		0, 0, 0, 11, 'E', 'X', 'A', 'M', 'P', 'L', 'E', '.', 'C', 'O', 'M',
+		0, 0, 0, 5, 'a', 'l', 'i', 'c', 'e',
+	}
+	err := ioutil.WriteFile(name, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DestroyCcache("FILE:" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("cache still there after DestroyCcache: %v", err)
+	}
+}
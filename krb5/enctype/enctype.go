@@ -0,0 +1,118 @@
+/*
+Package enctype defines the Kerberos encryption types, with their numbers
+from the IANA Kerberos parameters registry and their names as used in
+krb5.conf. It is pure Go, so it can be used without cgo.
+*/
+package enctype
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enctype is a Kerberos encryption type number.
+type Enctype int32
+
+// Encryption types.
+const (
+	DES_CBC_CRC                Enctype = 1
+	DES_CBC_MD4                Enctype = 2
+	DES_CBC_MD5                Enctype = 3
+	DES3_CBC_SHA1              Enctype = 16
+	AES128_CTS_HMAC_SHA1_96    Enctype = 17
+	AES256_CTS_HMAC_SHA1_96    Enctype = 18
+	AES128_CTS_HMAC_SHA256_128 Enctype = 19
+	AES256_CTS_HMAC_SHA384_192 Enctype = 20
+	ARCFOUR_HMAC               Enctype = 23
+	ARCFOUR_HMAC_EXP           Enctype = 24
+	CAMELLIA128_CTS_CMAC       Enctype = 25
+	CAMELLIA256_CTS_CMAC       Enctype = 26
+)
+
+var names = map[Enctype]string{
+	DES_CBC_CRC:                "des-cbc-crc",
+	DES_CBC_MD4:                "des-cbc-md4",
+	DES_CBC_MD5:                "des-cbc-md5",
+	DES3_CBC_SHA1:              "des3-cbc-sha1",
+	AES128_CTS_HMAC_SHA1_96:    "aes128-cts-hmac-sha1-96",
+	AES256_CTS_HMAC_SHA1_96:    "aes256-cts-hmac-sha1-96",
+	AES128_CTS_HMAC_SHA256_128: "aes128-cts-hmac-sha256-128",
+	AES256_CTS_HMAC_SHA384_192: "aes256-cts-hmac-sha384-192",
+	ARCFOUR_HMAC:               "arcfour-hmac",
+	ARCFOUR_HMAC_EXP:           "arcfour-hmac-exp",
+	CAMELLIA128_CTS_CMAC:       "camellia128-cts-cmac",
+	CAMELLIA256_CTS_CMAC:       "camellia256-cts-cmac",
+}
+
+// aliases are the other names krb5.conf accepts.
+var aliases = map[string]Enctype{
+	"des3-hmac-sha1":   DES3_CBC_SHA1,
+	"aes128-cts":       AES128_CTS_HMAC_SHA1_96,
+	"aes256-cts":       AES256_CTS_HMAC_SHA1_96,
+	"aes128-sha1":      AES128_CTS_HMAC_SHA1_96,
+	"aes256-sha1":      AES256_CTS_HMAC_SHA1_96,
+	"aes128-sha2":      AES128_CTS_HMAC_SHA256_128,
+	"aes256-sha2":      AES256_CTS_HMAC_SHA384_192,
+	"rc4-hmac":         ARCFOUR_HMAC,
+	"arcfour-hmac-md5": ARCFOUR_HMAC,
+	"rc4-hmac-exp":     ARCFOUR_HMAC_EXP,
+	"camellia128-cts":  CAMELLIA128_CTS_CMAC,
+	"camellia256-cts":  CAMELLIA256_CTS_CMAC,
+}
+
+// String returns the krb5.conf name of the encryption type, or its number
+// for unknown types.
+func (e Enctype) String() string {
+	if n, ok := names[e]; ok {
+		return n
+	}
+	return strconv.Itoa(int(e))
+}
+
+// Weak reports whether the encryption type is deprecated: the DES family,
+// RC4 and triple DES (RFC 6649, RFC 8429).
+func (e Enctype) Weak() bool {
+	switch e {
+	case DES_CBC_CRC, DES_CBC_MD4, DES_CBC_MD5, DES3_CBC_SHA1,
+		ARCFOUR_HMAC, ARCFOUR_HMAC_EXP:
+		return true
+	}
+	return false
+}
+
+// Parse returns the encryption type for a krb5.conf name, an alias, or a
+// number.
+func Parse(s string) (Enctype, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for e, n := range names {
+		if n == name {
+			return e, nil
+		}
+	}
+	if e, ok := aliases[name]; ok {
+		return e, nil
+	}
+	if n, err := strconv.ParseInt(name, 10, 32); err == nil {
+		return Enctype(n), nil
+	}
+	return 0, fmt.Errorf("unknown encryption type %q", s)
+}
+
+// ParseList parses a whitespace or comma separated list of encryption types,
+// as in the permitted_enctypes setting of krb5.conf.
+func ParseList(s string) ([]Enctype, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	list := make([]Enctype, 0, len(fields))
+	for _, f := range fields {
+		e, err := Parse(f)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
@@ -0,0 +1,440 @@
+/*
+Package keytab reads and writes MIT Kerberos keytab files (format versions
+0x0501 and 0x0502) in pure Go, without cgo or the Kerberos libraries.
+
+It is meant for provisioning and diagnostics, for instance to check that a
+keytab holds the expected service principal, kvno and encryption types
+before an acceptor fails on it at runtime.
+*/
+package keytab
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+)
+
+// Keytab format versions.
+const (
+	Version1 = 0x0501
+	Version2 = 0x0502
+)
+
+// KRB5_NT_PRINCIPAL is the default principal name type.
+const KRB5_NT_PRINCIPAL = 1
+
+// ErrFormat is returned when a keytab cannot be parsed.
+var ErrFormat = errors.New("malformed keytab")
+
+// A Principal is a Kerberos principal name.
+type Principal struct {
+	NameType   int32
+	Components []string
+	Realm      string
+}
+
+// ParsePrincipal parses a principal such as "HTTP/www.example.com@EXAMPLE.COM",
+// with name type KRB5_NT_PRINCIPAL. Backslash escapes are honoured.
+func ParsePrincipal(s string) (Principal, error) {
+	p := Principal{NameType: KRB5_NT_PRINCIPAL}
+
+	var cur strings.Builder
+	inRealm := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case c == '/' && !inRealm:
+			p.Components = append(p.Components, cur.String())
+			cur.Reset()
+		case c == '@' && !inRealm:
+			p.Components = append(p.Components, cur.String())
+			cur.Reset()
+			inRealm = true
+		default:
+			cur.WriteByte(c)
+		}
+	}
+
+	if !inRealm {
+		return p, fmt.Errorf("principal %q has no realm", s)
+	}
+	p.Realm = cur.String()
+
+	return p, nil
+}
+
+// String returns the principal in the usual "a/b@REALM" form.
+func (p Principal) String() string {
+	esc := strings.NewReplacer(`\`, `\\`, "/", `\/`, "@", `\@`)
+
+	parts := make([]string, len(p.Components))
+	for i, c := range p.Components {
+		parts[i] = esc.Replace(c)
+	}
+	return strings.Join(parts, "/") + "@" + strings.NewReplacer(`\`, `\\`, "@", `\@`).Replace(p.Realm)
+}
+
+// Equal reports whether two principals have the same components and realm.
+// The name type is not compared, as Kerberos does not either.
+func (p Principal) Equal(o Principal) bool {
+	if p.Realm != o.Realm || len(p.Components) != len(o.Components) {
+		return false
+	}
+	for i := range p.Components {
+		if p.Components[i] != o.Components[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// An Entry is one key in a keytab.
+type Entry struct {
+	Principal Principal
+	Timestamp time.Time
+	KVNO      uint32
+	Enctype   enctype.Enctype
+	Key       []byte
+}
+
+// String describes the entry without the key, in the style of klist -k.
+func (e Entry) String() string {
+	return fmt.Sprintf("%d %s %s (%s)", e.KVNO,
+		e.Timestamp.Format("2006-01-02 15:04:05"), e.Principal, e.Enctype)
+}
+
+// A Keytab is the contents of a keytab file.
+type Keytab struct {
+	Version int
+	Entries []Entry
+}
+
+// New returns an empty keytab in the current format.
+func New() *Keytab {
+	return &Keytab{Version: Version2}
+}
+
+// Load reads and parses a keytab file. A "FILE:" or "WRFILE:" prefix, as
+// in KRB5_KTNAME, is accepted.
+func Load(name string) (*Keytab, error) {
+	data, err := ioutil.ReadFile(Path(name))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Path strips a "FILE:" or "WRFILE:" prefix from a keytab name.
+func Path(name string) string {
+	for _, prefix := range []string{"FILE:", "WRFILE:"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// Parse parses the contents of a keytab file.
+func Parse(data []byte) (*Keytab, error) {
+	if len(data) < 2 || data[0] != 0x05 || (data[1] != 0x01 && data[1] != 0x02) {
+		return nil, fmt.Errorf("%w: bad version", ErrFormat)
+	}
+
+	kt := &Keytab{Version: int(data[0])<<8 | int(data[1])}
+	order := kt.byteOrder()
+
+	rest := data[2:]
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("%w: truncated record length", ErrFormat)
+		}
+		size := int32(order.Uint32(rest))
+		rest = rest[4:]
+
+		if size < 0 {
+			// a hole left by a deleted entry; negated in 64 bits, since
+			// -math.MinInt32 does not fit in an int32
+			hole := -int64(size)
+			if hole > int64(len(rest)) {
+				return nil, fmt.Errorf("%w: truncated hole", ErrFormat)
+			}
+			rest = rest[hole:]
+			continue
+		}
+		if size == 0 {
+			break
+		}
+		if int(size) > len(rest) {
+			return nil, fmt.Errorf("%w: truncated entry", ErrFormat)
+		}
+
+		e, err := kt.parseEntry(rest[:size])
+		if err != nil {
+			return nil, err
+		}
+		kt.Entries = append(kt.Entries, e)
+		rest = rest[size:]
+	}
+
+	return kt, nil
+}
+
+// Marshal returns the keytab in file format, without holes.
+func (kt *Keytab) Marshal() ([]byte, error) {
+	if kt.Version != Version1 && kt.Version != Version2 {
+		return nil, fmt.Errorf("unsupported keytab version %#x", kt.Version)
+	}
+	order := kt.byteOrder()
+
+	var buf bytes.Buffer
+	buf.Write([]byte{byte(kt.Version >> 8), byte(kt.Version)})
+
+	for _, e := range kt.Entries {
+		rec, err := kt.marshalEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&buf, order, int32(len(rec)))
+		buf.Write(rec)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Write writes the keytab to the file name atomically: it is written to a
+// temporary file in the same directory, synced and renamed into place.
+func (kt *Keytab) Write(name string, perm os.FileMode) error {
+	data, err := kt.Marshal()
+	if err != nil {
+		return err
+	}
+
+	path := Path(name)
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// Add appends an entry.
+func (kt *Keytab) Add(e Entry) {
+	kt.Entries = append(kt.Entries, e)
+}
+
+// Remove removes the entries for which match returns true, and returns how
+// many were removed.
+func (kt *Keytab) Remove(match func(e Entry) bool) int {
+	kept := kt.Entries[:0]
+	for _, e := range kt.Entries {
+		if !match(e) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(kt.Entries) - len(kept)
+	kt.Entries = kept
+	return n
+}
+
+// Find returns the entries for principal. A kvno of 0 matches any version
+// and an enctype of 0 any encryption type.
+func (kt *Keytab) Find(principal Principal, kvno uint32, etype enctype.Enctype) []Entry {
+	var found []Entry
+	for _, e := range kt.Entries {
+		if !e.Principal.Equal(principal) {
+			continue
+		}
+		if kvno != 0 && e.KVNO != kvno {
+			continue
+		}
+		if etype != 0 && e.Enctype != etype {
+			continue
+		}
+		found = append(found, e)
+	}
+	return found
+}
+
+// Principals returns the distinct principals in the keytab, in order of
+// first appearance.
+func (kt *Keytab) Principals() []Principal {
+	var ps []Principal
+	seen := make(map[string]bool)
+	for _, e := range kt.Entries {
+		s := e.Principal.String()
+		if !seen[s] {
+			seen[s] = true
+			ps = append(ps, e.Principal)
+		}
+	}
+	return ps
+}
+
+// byteOrder returns the integer encoding: big-endian for version 2. Version
+// 1 files use the byte order of the host that wrote them, taken here to be
+// little-endian as on all common hosts.
+func (kt *Keytab) byteOrder() binary.ByteOrder {
+	if kt.Version == Version1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// reader decodes the fields of one entry.
+type reader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = fmt.Errorf("%w: truncated field", ErrFormat)
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint16(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint32(b)
+}
+
+func (r *reader) octets() []byte {
+	return r.bytes(int(r.uint16()))
+}
+
+func (kt *Keytab) parseEntry(data []byte) (Entry, error) {
+	r := &reader{data: data, order: kt.byteOrder()}
+	e := Entry{Principal: Principal{NameType: KRB5_NT_PRINCIPAL}}
+
+	n := int(r.uint16())
+	if kt.Version == Version1 {
+		// version 1 counts the realm as a component
+		n--
+	}
+	e.Principal.Realm = string(r.octets())
+	for i := 0; i < n && r.err == nil; i++ {
+		e.Principal.Components = append(e.Principal.Components, string(r.octets()))
+	}
+	if kt.Version == Version2 {
+		e.Principal.NameType = int32(r.uint32())
+	}
+
+	e.Timestamp = time.Unix(int64(r.uint32()), 0)
+	e.KVNO = uint32(r.uint8())
+	e.Enctype = enctype.Enctype(r.uint16())
+	e.Key = append([]byte(nil), r.octets()...)
+
+	// the 32-bit kvno extension, if present and set, overrides the 8-bit one
+	if r.err == nil && len(r.data) >= 4 {
+		if kvno := r.uint32(); kvno != 0 {
+			e.KVNO = kvno
+		}
+	}
+
+	if r.err != nil {
+		return Entry{}, r.err
+	}
+	return e, nil
+}
+
+func (kt *Keytab) marshalEntry(e Entry) ([]byte, error) {
+	order := kt.byteOrder()
+	var buf bytes.Buffer
+
+	octets := func(b []byte) error {
+		if len(b) > 0xffff {
+			return fmt.Errorf("field too long in entry %v", e)
+		}
+		binary.Write(&buf, order, uint16(len(b)))
+		buf.Write(b)
+		return nil
+	}
+
+	n := len(e.Principal.Components)
+	if kt.Version == Version1 {
+		n++
+	}
+	binary.Write(&buf, order, uint16(n))
+
+	err := octets([]byte(e.Principal.Realm))
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range e.Principal.Components {
+		err = octets([]byte(c))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if kt.Version == Version2 {
+		binary.Write(&buf, order, e.Principal.NameType)
+	}
+
+	binary.Write(&buf, order, uint32(e.Timestamp.Unix()))
+	buf.WriteByte(byte(e.KVNO))
+	binary.Write(&buf, order, uint16(e.Enctype))
+	err = octets(e.Key)
+	if err != nil {
+		return nil, err
+	}
+	binary.Write(&buf, order, e.KVNO)
+
+	return buf.Bytes(), nil
+}
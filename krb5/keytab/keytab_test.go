@@ -0,0 +1,19 @@
+package keytab
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBadHole(t *testing.T) {
+	// a hole of math.MinInt32 bytes, whose size cannot be negated in 32 bits
+	_, err := Parse([]byte{5, 2, 0x80, 0, 0, 0})
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("Parse = %v, want %v", err, ErrFormat)
+	}
+
+	_, err = Parse([]byte{5, 2, 0xff, 0xff, 0xff, 0xfe, 0})
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("Parse = %v, want %v", err, ErrFormat)
+	}
+}
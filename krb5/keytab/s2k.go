@@ -0,0 +1,227 @@
+package keytab
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+)
+
+// DefaultSalt returns the default salt for a principal: the realm followed by
+// the name components, with no separators.
+func DefaultSalt(p Principal) string {
+	return p.Realm + strings.Join(p.Components, "")
+}
+
+// DefaultIterations returns the default PBKDF2 iteration count for an
+// encryption type: 4096 for RFC 3962 and 32768 for RFC 8009.
+func DefaultIterations(e enctype.Enctype) int {
+	switch e {
+	case enctype.AES128_CTS_HMAC_SHA256_128, enctype.AES256_CTS_HMAC_SHA384_192:
+		return 32768
+	}
+	return 4096
+}
+
+// StringToKey derives the key for password and salt, for the AES encryption
+// types of RFC 3962 (aes128/aes256-cts-hmac-sha1-96) and RFC 8009
+// (aes128-cts-hmac-sha256-128, aes256-cts-hmac-sha384-192). An iterations
+// value of 0 selects the default for the encryption type.
+func StringToKey(e enctype.Enctype, password, salt string, iterations int) ([]byte, error) {
+	if iterations == 0 {
+		iterations = DefaultIterations(e)
+	}
+	if iterations < 0 {
+		return nil, fmt.Errorf("invalid iteration count %d", iterations)
+	}
+
+	switch e {
+	case enctype.AES128_CTS_HMAC_SHA1_96:
+		return rfc3962StringToKey(password, salt, iterations, 16)
+	case enctype.AES256_CTS_HMAC_SHA1_96:
+		return rfc3962StringToKey(password, salt, iterations, 32)
+	case enctype.AES128_CTS_HMAC_SHA256_128:
+		return rfc8009StringToKey(e, sha256.New, password, salt, iterations, 16)
+	case enctype.AES256_CTS_HMAC_SHA384_192:
+		return rfc8009StringToKey(e, sha512.New384, password, salt, iterations, 32)
+	}
+
+	return nil, fmt.Errorf("string-to-key not supported for %v", e)
+}
+
+// NewPasswordEntry returns a keytab entry with the key derived from password,
+// using the default salt and iteration count.
+func NewPasswordEntry(p Principal, kvno uint32, e enctype.Enctype, password string) (Entry, error) {
+	key, err := StringToKey(e, password, DefaultSalt(p), 0)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return Entry{
+		Principal: p,
+		Timestamp: time.Now(),
+		KVNO:      kvno,
+		Enctype:   e,
+		Key:       key,
+	}, nil
+}
+
+// AddPassword adds entries for principal with keys derived from password for
+// each of enctypes, as ktutil's addent -password does.
+func (kt *Keytab) AddPassword(p Principal, kvno uint32, password string,
+	enctypes ...enctype.Enctype) error {
+
+	for _, e := range enctypes {
+		entry, err := NewPasswordEntry(p, kvno, e, password)
+		if err != nil {
+			return err
+		}
+		kt.Add(entry)
+	}
+	return nil
+}
+
+// rfc3962StringToKey is DK(random-to-key(PBKDF2-HMAC-SHA1(...)), "kerberos").
+func rfc3962StringToKey(password, salt string, iterations, keyLen int) ([]byte, error) {
+	tkey := pbkdf2([]byte(password), []byte(salt), iterations, keyLen, sha1.New)
+
+	block, err := aes.NewCipher(tkey)
+	if err != nil {
+		return nil, err
+	}
+
+	// DR: encrypt the n-folded constant, feeding each output block back in
+	// until there are enough bits. One block needs no CTS, so this is ECB.
+	in := nfold([]byte("kerberos"), block.BlockSize())
+	key := make([]byte, 0, keyLen+block.BlockSize())
+	for len(key) < keyLen {
+		out := make([]byte, block.BlockSize())
+		block.Encrypt(out, in)
+		key = append(key, out...)
+		in = out
+	}
+
+	return key[:keyLen], nil
+}
+
+// rfc8009StringToKey is KDF-HMAC-SHA2(PBKDF2-HMAC-SHA2(...), "kerberos"),
+// with the encryption type name prefixed to the salt.
+func rfc8009StringToKey(e enctype.Enctype, h func() hash.Hash,
+	password, salt string, iterations, keyLen int) ([]byte, error) {
+
+	saltp := append([]byte(e.String()), 0)
+	saltp = append(saltp, salt...)
+	tkey := pbkdf2([]byte(password), saltp, iterations, keyLen, h)
+
+	// K1 = HMAC(key, 00000001 | label | 00 | k), truncated to k bits
+	msg := []byte{0, 0, 0, 1}
+	msg = append(msg, "kerberos"...)
+	msg = append(msg, 0)
+	var k [4]byte
+	binary.BigEndian.PutUint32(k[:], uint32(keyLen*8))
+	msg = append(msg, k[:]...)
+
+	mac := hmac.New(h, tkey)
+	mac.Write(msg)
+
+	return mac.Sum(nil)[:keyLen], nil
+}
+
+// pbkdf2 implements PBKDF2 from RFC 8018 with HMAC as the PRF.
+func pbkdf2(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+
+	var out []byte
+	var buf [4]byte
+	u := make([]byte, hashLen)
+	t := make([]byte, hashLen)
+	for block := uint32(1); len(out) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], block)
+		prf.Write(buf[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		out = append(out, t...)
+	}
+
+	return out[:keyLen]
+}
+
+// nfold implements the n-fold operation of RFC 3961 section 5.1, stretching
+// or folding in to n bytes.
+func nfold(in []byte, n int) []byte {
+	inBits := len(in) * 8
+	outBits := n * 8
+	lcm := inBits * outBits / gcd(inBits, outBits)
+
+	// concatenate copies of in, each rotated right by a further 13 bits,
+	// up to lcm bits, and add them in n-byte chunks with end-around carry
+	buf := make([]byte, lcm/8)
+	for i := 0; i < lcm/inBits; i++ {
+		rot := rotateRight(in, 13*i)
+		copy(buf[i*len(in):], rot)
+	}
+
+	out := make([]byte, n)
+	for i := 0; i < len(buf); i += n {
+		out = onesComplementAdd(out, buf[i:i+n])
+	}
+	return out
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func rotateRight(in []byte, bits int) []byte {
+	nbits := len(in) * 8
+	bits %= nbits
+	out := make([]byte, len(in))
+	for i := 0; i < nbits; i++ {
+		src := (i - bits + nbits) % nbits
+		if in[src/8]&(0x80>>uint(src%8)) != 0 {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+func onesComplementAdd(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	carry := 0
+	for i := len(a) - 1; i >= 0; i-- {
+		s := int(a[i]) + int(b[i]) + carry
+		out[i] = byte(s)
+		carry = s >> 8
+	}
+	for carry != 0 {
+		for i := len(out) - 1; i >= 0 && carry != 0; i-- {
+			s := int(out[i]) + carry
+			out[i] = byte(s)
+			carry = s >> 8
+		}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package keytab
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 3961 appendix A.1
+func TestNfold(t *testing.T) {
+	for _, tc := range []struct {
+		bits int
+		in   string
+		want string
+	}{
+		{64, "012345", "be072631276b1955"},
+		{56, "password", "78a07b6caf85fa"},
+		{64, "Rough Consensus, and Running Code", "bb6ed30870b7f0e0"},
+		{168, "password", "59e4a8ca7c0385c3c37b3f6d2000247cb6e6bd5b3e"},
+		{192, "MASSACHVSETTS INSTITVTE OF TECHNOLOGY",
+			"db3b0d8f0b061e603282b308a50841229ad798fab9540c1b"},
+		{168, "Q", "518a54a215a8452a518a54a215a8452a518a54a215"},
+		{168, "ba", "fb25d531ae8974499f52fd92ea9857c4ba24cf297e"},
+		{64, "kerberos", "6b65726265726f73"},
+		{128, "kerberos", "6b65726265726f737b9b5b2b93132b93"},
+		{168, "kerberos", "8372c236344e5f1550cd0747e15d62ca7a5a3bcea4"},
+		{256, "kerberos",
+			"6b65726265726f737b9b5b2b93132b935c9bdcdad95c9899c4cae4dee6d6cae4"},
+	} {
+		got := nfold([]byte(tc.in), tc.bits/8)
+		if want := unhex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("%d-fold(%q) = %x, want %x", tc.bits, tc.in, got, want)
+		}
+	}
+}
+
+// RFC 3962 appendix B
+func TestStringToKeyRFC3962(t *testing.T) {
+	for _, tc := range []struct {
+		iterations int
+		password   string
+		salt       string
+		aes128     string
+		aes256     string
+	}{
+		{1, "password", "ATHENA.MIT.EDUraeburn",
+			"42263c6e89f4fc28b8df68ee09799f15",
+			"fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161"},
+		{2, "password", "ATHENA.MIT.EDUraeburn",
+			"c651bf29e2300ac27fa469d693bdda13",
+			"a2e16d16b36069c135d5e9d2e25f896102685618b95914b467c67622225824ff"},
+		{1200, "password", "ATHENA.MIT.EDUraeburn",
+			"4c01cd46d632d01e6dbe230a01ed642a",
+			"55a6ac740ad17b4846941051e1e8b0a7548d93b0ab30a8bc3ff16280382b8c2a"},
+		{5, "password", "\x12\x34\x56\x78\x78\x56\x34\x12",
+			"e9b23d52273747dd5c35cb55be619d8e",
+			"97a4e786be20d81a382d5ebc96d5909cabcdadc87ca48f574504159f16c36e31"},
+		{1200, strings.Repeat("X", 64), "pass phrase equals block size",
+			"59d1bb789a828b1aa54ef9c2883f69ed",
+			"89adee3608db8bc71f1bfbfe459486b05618b70cbae22092534e56c553ba4b34"},
+		{1200, strings.Repeat("X", 65), "pass phrase exceeds block size",
+			"cb8005dc5f90179a7f02104c0018751d",
+			"d78c5c9cb872a8c9dad4697f0bb5b2d21496c82beb2caeda2112fceea057401b"},
+		{50, "\xf0\x9d\x84\x9e", "EXAMPLE.COMpianist",
+			"f149c1f2e154a73452d43e7fe62a56e5",
+			"4b6d9839f84406df1f09cc166db4b83c571848b784a3d6bdc346589a3e393f9e"},
+	} {
+		for _, k := range []struct {
+			e    enctype.Enctype
+			want string
+		}{
+			{enctype.AES128_CTS_HMAC_SHA1_96, tc.aes128},
+			{enctype.AES256_CTS_HMAC_SHA1_96, tc.aes256},
+		} {
+			got, err := StringToKey(k.e, tc.password, tc.salt, tc.iterations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := unhex(t, k.want); !bytes.Equal(got, want) {
+				t.Errorf("%v, %d iterations, salt %q: key %x, want %x",
+					k.e, tc.iterations, tc.salt, got, want)
+			}
+		}
+	}
+}
+
+// RFC 8009 appendix A, sample results for string-to-key
+func TestStringToKeyRFC8009(t *testing.T) {
+	salt := string(unhex(t, "10df9dd783e5bc8acea1730e74355f61")) + "ATHENA.MIT.EDUraeburn"
+
+	for _, tc := range []struct {
+		e    enctype.Enctype
+		want string
+	}{
+		{enctype.AES128_CTS_HMAC_SHA256_128, "089bca48b105ea6ea77ca5d2f39dc5e7"},
+		{enctype.AES256_CTS_HMAC_SHA384_192,
+			"45bd806dbf6a833a9cffc1c94589a222367a79bc21c413718906e9f578a78467"},
+	} {
+		got, err := StringToKey(tc.e, "password", salt, 32768)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := unhex(t, tc.want); !bytes.Equal(got, want) {
+			t.Errorf("%v: key %x, want %x", tc.e, got, want)
+		}
+	}
+}
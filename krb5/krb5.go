@@ -0,0 +1,83 @@
+/*
+Package krb5 wraps the Kerberos-specific GSSAPI extensions of MIT Kerberos
+(gssapi_krb5.h), for use together with the gssapi package.
+
+It lets a process pick credential caches and keytabs per call instead of
+through the KRB5CCNAME and KRB5_KTNAME environment variables.
+
+To close replay caches with MIT 1.18 and later, the package uses the private
+k5_rc_close, looked up at run time. Build with -tags krb5_no_private_symbols
+to use only public symbols.
+*/
+package krb5
+
+/*
+#cgo linux LDFLAGS: -lgssapi_krb5 -lkrb5 -ldl
+
+#include <stdlib.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_krb5.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	gssapi "github.com/lixiangyun/go-gssapi"
+)
+
+// Error is a krb5 library error.
+type Error struct {
+	Code    int32
+	Message string
+}
+
+// Error returns the krb5 error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("krb5: %s (%d)", e.Message, e.Code)
+}
+
+// krb5Error converts a krb5_error_code into an Error, or nil.
+func krb5Error(ctx C.krb5_context, code C.krb5_error_code) error {
+	if code == 0 {
+		return nil
+	}
+
+	msg := C.krb5_get_error_message(ctx, code)
+	defer C.krb5_free_error_message(ctx, msg)
+
+	return &Error{Code: int32(code), Message: C.GoString(msg)}
+}
+
+// withContext runs fn with a fresh krb5_context.
+func withContext(fn func(ctx C.krb5_context) error) error {
+	var ctx C.krb5_context
+	code := C.krb5_init_context(&ctx)
+	if code != 0 {
+		return &Error{Code: int32(code), Message: "cannot initialize krb5 context"}
+	}
+	defer C.krb5_free_context(ctx)
+
+	return fn(ctx)
+}
+
+// gssStatus converts a GSSAPI status into the gssapi package's error type.
+func gssStatus(maj, min C.OM_uint32) error {
+	return gssapi.MakeStatus(uint32(maj), uint32(min))
+}
+
+// credHandle returns the C handle of a gssapi.CredId.
+func credHandle(cred *gssapi.CredId) C.gss_cred_id_t {
+	if cred == nil {
+		return nil
+	}
+	return C.gss_cred_id_t(unsafe.Pointer(cred.C_gss_cred_id_t))
+}
+
+// credHandlePtr returns a pointer to the C handle of a gssapi.CredId, for use
+// as an output parameter.
+func credHandlePtr(cred *gssapi.CredId) *C.gss_cred_id_t {
+	return (*C.gss_cred_id_t)(unsafe.Pointer(&cred.C_gss_cred_id_t))
+}
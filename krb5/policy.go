@@ -0,0 +1,112 @@
+package krb5
+
+/*
+#include <stdlib.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_krb5.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	gssapi "github.com/lixiangyun/go-gssapi"
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+)
+
+// Replay cache names, for the gssapi.CredStoreRcache credential store key.
+// For instance, a high-throughput acceptor that relies on other replay
+// protection can acquire its credential with
+// gssapi.CredStore{gssapi.CredStoreRcache: krb5.RcacheNone}.
+const (
+	RcacheDefault = "dfl:"
+	RcacheNone    = "none:"
+	RcacheFile2   = "file2:"
+)
+
+// ErrEnctypeNotAllowed is returned by CheckEnctype when a context uses an
+// encryption type outside the allowed set.
+var ErrEnctypeNotAllowed = errors.New("encryption type not allowed")
+
+// SetAllowableEnctypes implements gss_krb5_set_allowable_enctypes, limiting
+// the encryption types used for contexts initiated with cred, for instance
+// to AES-SHA2 only.
+func SetAllowableEnctypes(cred *gssapi.CredId, enctypes []enctype.Enctype) error {
+	if len(enctypes) == 0 {
+		return errors.New("no encryption types given")
+	}
+
+	ktypes := C.malloc(C.size_t(len(enctypes)) * C.size_t(unsafe.Sizeof(C.krb5_enctype(0))))
+	if ktypes == nil {
+		return gssapi.ErrMallocFailed
+	}
+	defer C.free(ktypes)
+
+	k := unsafe.Slice((*C.krb5_enctype)(ktypes), len(enctypes))
+	for i, e := range enctypes {
+		k[i] = C.krb5_enctype(e)
+	}
+
+	min := C.OM_uint32(0)
+	maj := C.gss_krb5_set_allowable_enctypes(&min, credHandle(cred),
+		C.OM_uint32(len(enctypes)), (*C.krb5_enctype)(ktypes))
+	return gssStatus(maj, min)
+}
+
+// SetCredRcache implements gss_krb5_set_cred_rcache, giving an acceptor
+// credential its own replay cache, obtained with krb5_get_server_rcache for
+// the service name piece. To pick the replay cache type, such as
+// RcacheNone, acquire the credential with gssapi.CredStoreRcache instead.
+func SetCredRcache(cred *gssapi.CredId, piece string) error {
+	return withContext(func(ctx C.krb5_context) error {
+		cpiece := C.CString(piece)
+		defer C.free(unsafe.Pointer(cpiece))
+
+		data := C.krb5_data{length: C.uint(len(piece)), data: cpiece}
+
+		var rc C.krb5_rcache
+		code := C.krb5_get_server_rcache(ctx, &data, &rc)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+
+		// on success the credential owns the rcache
+		min := C.OM_uint32(0)
+		maj := C.gss_krb5_set_cred_rcache(&min, credHandle(cred), rc)
+		err := gssStatus(maj, min)
+		if err != nil {
+			cerr := closeRcache(ctx, rc)
+			if cerr != nil {
+				return fmt.Errorf("%w (%v)", err, cerr)
+			}
+		}
+		return err
+	})
+}
+
+// SessionEnctype returns the encryption type of the session key of an
+// established krb5 context, that is the one actually negotiated, as reported
+// by gssapi.CtxId.SessionKey.
+func SessionEnctype(ctx *gssapi.CtxId) (enctype.Enctype, error) {
+	_, e, err := ctx.SessionKey()
+	return e, err
+}
+
+// CheckEnctype returns the encryption type negotiated for ctx, and
+// ErrEnctypeNotAllowed if it is not one of allowed.
+func CheckEnctype(ctx *gssapi.CtxId, allowed []enctype.Enctype) (enctype.Enctype, error) {
+	e, err := SessionEnctype(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, a := range allowed {
+		if a == e {
+			return e, nil
+		}
+	}
+	return e, fmt.Errorf("%w: %v", ErrEnctypeNotAllowed, e)
+}
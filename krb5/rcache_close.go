@@ -0,0 +1,49 @@
+//go:build !krb5_no_private_symbols
+// +build !krb5_no_private_symbols
+
+package krb5
+
+// This file closes replay caches with krb5_rc_close, or with MIT's private
+// k5_rc_close where that is all the library exports, as in MIT 1.18 and
+// later. Build with the krb5_no_private_symbols tag to leave the private
+// symbol alone (see rcache_close_public.go); closing a replay cache then
+// fails with those releases.
+
+/*
+#define _GNU_SOURCE
+#include <dlfcn.h>
+#include <krb5.h>
+
+// close_rcache closes a replay cache, looking the function up at run time.
+// It returns -1 if there is none.
+static int
+close_rcache(krb5_context ctx, krb5_rcache rc)
+{
+	krb5_error_code (*rc_close)(krb5_context, krb5_rcache);
+	void (*k5_rc_close)(krb5_context, krb5_rcache);
+
+	rc_close = (krb5_error_code (*)(krb5_context, krb5_rcache))dlsym(RTLD_DEFAULT, "krb5_rc_close");
+	if (rc_close != NULL) {
+		rc_close(ctx, rc);
+		return 0;
+	}
+	k5_rc_close = (void (*)(krb5_context, krb5_rcache))dlsym(RTLD_DEFAULT, "k5_rc_close");
+	if (k5_rc_close != NULL) {
+		k5_rc_close(ctx, rc);
+		return 0;
+	}
+	return -1;
+}
+*/
+import "C"
+
+import "errors"
+
+// closeRcache closes rc, or returns an error if the library exports no
+// function to do so, in which case rc is leaked.
+func closeRcache(ctx C.krb5_context, rc C.krb5_rcache) error {
+	if C.close_rcache(ctx, rc) != 0 {
+		return errors.New("neither krb5_rc_close nor k5_rc_close found; replay cache leaked")
+	}
+	return nil
+}
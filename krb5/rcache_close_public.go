@@ -0,0 +1,41 @@
+//go:build krb5_no_private_symbols
+// +build krb5_no_private_symbols
+
+package krb5
+
+// This file closes replay caches with the public krb5_rc_close only, for
+// builds with the krb5_no_private_symbols tag. MIT 1.18 and later do not
+// export it, so there closeRcache fails and the replay cache is leaked.
+
+/*
+#define _GNU_SOURCE
+#include <dlfcn.h>
+#include <krb5.h>
+
+// close_rcache closes a replay cache, looking the function up at run time.
+// It returns -1 if there is none.
+static int
+close_rcache(krb5_context ctx, krb5_rcache rc)
+{
+	krb5_error_code (*rc_close)(krb5_context, krb5_rcache);
+
+	rc_close = (krb5_error_code (*)(krb5_context, krb5_rcache))dlsym(RTLD_DEFAULT, "krb5_rc_close");
+	if (rc_close == NULL) {
+		return -1;
+	}
+	rc_close(ctx, rc);
+	return 0;
+}
+*/
+import "C"
+
+import "errors"
+
+// closeRcache closes rc, or returns an error if the library does not export
+// krb5_rc_close, in which case rc is leaked.
+func closeRcache(ctx C.krb5_context, rc C.krb5_rcache) error {
+	if C.close_rcache(ctx, rc) != 0 {
+		return errors.New("krb5_rc_close not found; replay cache leaked")
+	}
+	return nil
+}
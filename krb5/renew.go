@@ -0,0 +1,80 @@
+package krb5
+
+/*
+#include <stdlib.h>
+#include <gssapi/gssapi.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	gssapi "github.com/lixiangyun/go-gssapi"
+)
+
+// RenewCcache renews the ticket-granting ticket in the credential cache
+// ccache with krb5_get_renewed_creds, as kinit -R does, and replaces the
+// contents of the cache with the renewed ticket. It fails if the ticket is
+// not renewable or is past its renew-until time.
+func RenewCcache(ccache string) error {
+	return withContext(func(ctx C.krb5_context) error {
+		ccname := C.CString(ccache)
+		defer C.free(unsafe.Pointer(ccname))
+
+		var cc C.krb5_ccache
+		code := C.krb5_cc_resolve(ctx, ccname, &cc)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		defer C.krb5_cc_close(ctx, cc)
+
+		var princ C.krb5_principal
+		code = C.krb5_cc_get_principal(ctx, cc, &princ)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		defer C.krb5_free_principal(ctx, princ)
+
+		var creds C.krb5_creds
+		code = C.krb5_get_renewed_creds(ctx, &creds, princ, cc, nil)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		defer C.krb5_free_cred_contents(ctx, &creds)
+
+		code = C.krb5_cc_initialize(ctx, cc, princ)
+		if code != 0 {
+			return krb5Error(ctx, code)
+		}
+		code = C.krb5_cc_store_cred(ctx, cc, &creds)
+		return krb5Error(ctx, code)
+	})
+}
+
+// RenewingCcacheSource returns a gssapi.CredSource like gssapi.CcacheSource,
+// except that it first renews the ticket-granting ticket in ccache with
+// RenewCcache. A gssapi.CredManager using it keeps a renewable ticket alive
+// up to its renew-until time without kinit -R or k5start.
+//
+// If renewal fails the source fails with that error, even if the cache still
+// holds a ticket, so that the manager's OnFailure is called and it retries
+// rather than handing out a ticket about to expire. Use gssapi.CcacheSource
+// for tickets that are not renewable. desiredName must stay valid while the
+// source is in use.
+func RenewingCcacheSource(desiredName *gssapi.Name, ccache string) gssapi.CredSource {
+	return renewingSource(gssapi.CcacheSource(desiredName, ccache), func() error {
+		return RenewCcache(ccache)
+	})
+}
+
+// renewingSource returns a gssapi.CredSource calling renew, then source.
+func renewingSource(source gssapi.CredSource, renew func() error) gssapi.CredSource {
+	return func() (*gssapi.CredId, error) {
+		err := renew()
+		if err != nil {
+			return nil, err
+		}
+		return source()
+	}
+}
@@ -0,0 +1,76 @@
+package krb5
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	gssapi "github.com/lixiangyun/go-gssapi"
+	"github.com/lixiangyun/go-gssapi/krb5/enctype"
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// keytabSource returns a source of acceptor credentials, which can be had
+// without a KDC, for a keytab with one made up key.
+func keytabSource(t *testing.T) gssapi.CredSource {
+	t.Helper()
+
+	p, err := keytab.ParsePrincipal("host/test.example.com@EXAMPLE.COM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kt := keytab.New()
+	err = kt.AddPassword(p, 1, "password", enctype.AES256_CTS_HMAC_SHA1_96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ktname := filepath.Join(t.TempDir(), "test.keytab")
+	err = kt.Write(ktname, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() (*gssapi.CredId, error) {
+		cred, actualMechs, _, err := gssapi.AcquireCredFrom(gssapi.GSS_C_NO_NAME(), 0,
+			gssapi.GSS_C_NO_OID_SET, gssapi.GSS_C_ACCEPT,
+			gssapi.CredStore{gssapi.CredStoreKeytab: "FILE:" + ktname})
+		if err != nil {
+			return nil, err
+		}
+		actualMechs.Release()
+		return cred, nil
+	}
+}
+
+func TestRenewingSourceFailure(t *testing.T) {
+	errRenew := errors.New("ticket not renewable")
+	var renewErr error
+	source := renewingSource(keytabSource(t), func() error { return renewErr })
+
+	m, err := gssapi.NewCredManager(source, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var failure error
+	m.OnFailure = func(err error) { failure = err }
+
+	renewErr = errRenew
+	err = m.Renew()
+	if err != errRenew {
+		t.Errorf("Renew = %v, want %v", err, errRenew)
+	}
+	if failure != errRenew {
+		t.Errorf("OnFailure got %v, want %v", failure, errRenew)
+	}
+	if _, err := m.Expiry(); err != errRenew {
+		t.Errorf("Expiry error = %v, want %v", err, errRenew)
+	}
+
+	renewErr = nil
+	err = m.Renew()
+	if err != nil {
+		t.Errorf("Renew after recovery = %v", err)
+	}
+}
@@ -37,35 +37,63 @@ const gss_OID_desc *_GSS_MECH_KRB5_OLD             = & (gss_OID_desc) {  5, "\x2
 const gss_OID_desc *_GSS_MECH_SPNEGO               = & (gss_OID_desc) {  6, "\x2b\x06\x01\x05\x05\x02" };
 const gss_OID_desc *_GSS_MECH_IAKERB               = & (gss_OID_desc) {  6, "\x2b\x06\x01\x05\x02\x05" };
 const gss_OID_desc *_GSS_MECH_NTLMSSP              = & (gss_OID_desc) { 10, "\x2b\x06\x01\x04\x01\x82\x37\x02\x02\x0a" };
+
+// credential options and inquiries
+// { 1 2 752 43 13 1 }, Heimdal
+const gss_OID_desc *_GSS_KRB5_COPY_CCACHE_X         = & (gss_OID_desc) {  6, "\x2a\x85\x70\x2b\x0d\x01" };
+// { 1 2 752 43 13 29 }, Heimdal, also in MIT
+const gss_OID_desc *_GSS_KRB5_CRED_NO_CI_FLAGS_X    = & (gss_OID_desc) {  6, "\x2a\x85\x70\x2b\x0d\x1d" };
+// { 1 2 840 113554 1 2 2 5 14 }, MIT
+const gss_OID_desc *_GSS_KRB5_GET_CRED_IMPERSONATOR = & (gss_OID_desc) { 11, "\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x0e" };
+
+// security context inquiries
+// { 1 2 840 113554 1 2 2 5 5 }, MIT, also in Heimdal
+const gss_OID_desc *_GSS_C_INQ_SSPI_SESSION_KEY     = & (gss_OID_desc) { 11, "\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x05" };
+// { 1 2 840 113554 1 2 2 5 16 }, MIT
+const gss_OID_desc *_GSS_C_INQ_NEGOEX_KEY           = & (gss_OID_desc) { 11, "\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x10" };
+// { 1 2 840 113554 1 2 2 5 17 }, MIT
+const gss_OID_desc *_GSS_C_INQ_NEGOEX_VERIFY_KEY    = & (gss_OID_desc) { 11, "\x2a\x86\x48\x86\xf7\x12\x01\x02\x02\x05\x11" };
 */
 import "C"
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
 
 // constants are a number of constant initialized in initConstants.
 var (
-	GSS_C_NO_BUFFER     *Buffer
-	GSS_C_NO_OID        *OID
-	GSS_C_NO_OID_SET    *OIDSet
-	GSS_C_NO_CONTEXT    *CtxId
-	GSS_C_NO_CREDENTIAL *CredId
+	GSS_C_NO_BUFFER                *Buffer
+	GSS_C_NO_OID                   *OID
+	GSS_C_NO_OID_SET               *OIDSet
+	GSS_C_NO_CONTEXT               *CtxId
+	GSS_C_NO_CREDENTIAL            *CredId
 	// when adding new OID constants also need to update OID.DebugString
-	GSS_C_NT_USER_NAME           *OID
-	GSS_C_NT_MACHINE_UID_NAME    *OID
-	GSS_C_NT_STRING_UID_NAME     *OID
-	GSS_C_NT_HOSTBASED_SERVICE_X *OID
-	GSS_C_NT_HOSTBASED_SERVICE   *OID
-	GSS_C_NT_ANONYMOUS           *OID
-	GSS_C_NT_EXPORT_NAME         *OID
-	GSS_KRB5_NT_PRINCIPAL_NAME   *OID
-	GSS_KRB5_NT_PRINCIPAL        *OID
-	GSS_MECH_KRB5                *OID
-	GSS_MECH_KRB5_LEGACY         *OID
-	GSS_MECH_KRB5_OLD            *OID
-	GSS_MECH_SPNEGO              *OID
-	GSS_MECH_IAKERB              *OID
-	GSS_MECH_NTLMSSP             *OID
-	GSS_C_NO_CHANNEL_BINDINGS    ChannelBindings // implicitly initialized as nil
+	GSS_C_NT_USER_NAME             *OID
+	GSS_C_NT_MACHINE_UID_NAME      *OID
+	GSS_C_NT_STRING_UID_NAME       *OID
+	GSS_C_NT_HOSTBASED_SERVICE_X   *OID
+	GSS_C_NT_HOSTBASED_SERVICE     *OID
+	GSS_C_NT_ANONYMOUS             *OID
+	GSS_C_NT_EXPORT_NAME           *OID
+	GSS_KRB5_NT_PRINCIPAL_NAME     *OID
+	GSS_KRB5_NT_PRINCIPAL          *OID
+	GSS_MECH_KRB5                  *OID
+	GSS_MECH_KRB5_LEGACY           *OID
+	GSS_MECH_KRB5_OLD              *OID
+	GSS_MECH_SPNEGO                *OID
+	GSS_MECH_IAKERB                *OID
+	GSS_MECH_NTLMSSP               *OID
+	GSS_KRB5_COPY_CCACHE_X         *OID
+	GSS_KRB5_CRED_NO_CI_FLAGS_X    *OID
+	GSS_KRB5_GET_CRED_IMPERSONATOR *OID
+	GSS_C_INQ_SSPI_SESSION_KEY     *OID
+	GSS_C_INQ_NEGOEX_KEY           *OID
+	GSS_C_INQ_NEGOEX_VERIFY_KEY    *OID
+	GSS_C_NO_CHANNEL_BINDINGS      ChannelBindings // implicitly initialized as nil
 )
 
 func init()  {
@@ -95,8 +123,20 @@ func init()  {
 	GSS_MECH_SPNEGO = &OID{C_gss_OID: C._GSS_MECH_SPNEGO}
 	GSS_MECH_IAKERB = &OID{C_gss_OID: C._GSS_MECH_IAKERB}
 	GSS_MECH_NTLMSSP = &OID{C_gss_OID: C._GSS_MECH_NTLMSSP}
+
+	GSS_KRB5_COPY_CCACHE_X = &OID{C_gss_OID: C._GSS_KRB5_COPY_CCACHE_X}
+	GSS_KRB5_CRED_NO_CI_FLAGS_X = &OID{C_gss_OID: C._GSS_KRB5_CRED_NO_CI_FLAGS_X}
+	GSS_KRB5_GET_CRED_IMPERSONATOR = &OID{C_gss_OID: C._GSS_KRB5_GET_CRED_IMPERSONATOR}
+
+	GSS_C_INQ_SSPI_SESSION_KEY = &OID{C_gss_OID: C._GSS_C_INQ_SSPI_SESSION_KEY}
+	GSS_C_INQ_NEGOEX_KEY = &OID{C_gss_OID: C._GSS_C_INQ_NEGOEX_KEY}
+	GSS_C_INQ_NEGOEX_VERIFY_KEY = &OID{C_gss_OID: C._GSS_C_INQ_NEGOEX_VERIFY_KEY}
 }
 
+// Krb5Set sets the krb5.conf and the default keytab through the KRB5_CONFIG
+// and KRB5_KTNAME environment variables. The keytab need not exist yet; call
+// CheckKeytab to have a bad one reported before the first context is
+// accepted.
 func Krb5Set(Krb5Config string, Krb5Ktname string) error {
 	err := os.Setenv("KRB5_CONFIG", Krb5Config)
 	if err != nil {
@@ -108,3 +148,29 @@ func Krb5Set(Krb5Config string, Krb5Ktname string) error {
 	}
 	return nil
 }
+
+// CheckKeytab reads the keytab name, as given to Krb5Set, and returns an
+// error if it is missing, malformed or empty. Only FILE keytabs are read;
+// other types, and an empty name, are left to the library.
+func CheckKeytab(name string) error {
+	if name == "" || !isFileKeytab(name) {
+		return nil
+	}
+
+	kt, err := keytab.Load(name)
+	if err != nil {
+		return fmt.Errorf("keytab %s: %v", name, err)
+	}
+	if len(kt.Entries) == 0 {
+		return fmt.Errorf("keytab %s: no entries", name)
+	}
+	return nil
+}
+
+// isFileKeytab reports whether a keytab name refers to a FILE keytab, with
+// or without the type prefix.
+func isFileKeytab(name string) bool {
+	i := strings.Index(name, ":")
+	return i < 0 || strings.Contains(name[:i], "/") ||
+		name[:i] == "FILE" || name[:i] == "WRFILE"
+}
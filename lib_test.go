@@ -0,0 +1,49 @@
+package gssapi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckKeytab(t *testing.T) {
+	k := newTestKrb5(t)
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.keytab")
+	err := ioutil.WriteFile(empty, []byte{0x05, 0x02}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.keytab")
+	err = ioutil.WriteFile(bad, []byte("not a keytab"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"", true},
+		{k.keytab, true},
+		{"FILE:" + k.keytab, true},
+		{"MEMORY:test", true},
+		{filepath.Join(dir, "missing.keytab"), false},
+		{"FILE:" + filepath.Join(dir, "missing.keytab"), false},
+		{empty, false},
+		{bad, false},
+	} {
+		err := CheckKeytab(tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckKeytab(%q) = %v", tc.name, err)
+		}
+	}
+
+	// Krb5Set leaves the keytab to be written later
+	t.Setenv("KRB5_CONFIG", "")
+	t.Setenv("KRB5_KTNAME", "")
+	err = Krb5Set(filepath.Join(dir, "krb5.conf"), filepath.Join(dir, "later.keytab"))
+	if err != nil {
+		t.Errorf("Krb5Set with a keytab yet to be written = %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+Package localname maps Kerberos principal names to local short names using the
+auth_to_local rule language of MIT Kerberos and Hadoop.
+
+It is implemented in pure Go and does not consult the system krb5.conf, so a
+service can carry its own rules and get the same mapping on every host. The
+input is the principal string as returned by Name.Display(), for instance
+"HTTP/www.example.com@EXAMPLE.COM".
+
+The supported rules are:
+
+	DEFAULT
+	RULE:[n:fmt](regex)s/pattern/replacement/g/L
+
+Where MIT and Hadoop differ, Hadoop's KerberosName is followed. DEFAULT maps
+any principal in the default realm to its first component, so that
+"nn/host@REALM" becomes "nn"; MIT only maps single-component principals. For
+RULE, n is the number of components the principal must have and fmt builds
+the string to match, where $0 is the realm and $1..$n are the components; a
+'$' not followed by a valid index is an error. The (regex) part must match
+the whole formatted string. In the optional substitution the replacement
+uses Java syntax: $1 is the first group even in "$1x", and a backslash
+escapes the next character. The trailing g replaces all matches and /L
+lowercases the result. A result still containing '/' or '@' is an error.
+
+A principal without a realm is taken to be in the default realm. An empty
+principal, or one with an empty component or realm, is rejected with
+ErrMalformedPrincipal.
+*/
+package localname
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoMatch is returned by Map when none of the rules apply to a principal.
+var ErrNoMatch = errors.New("no auth_to_local rule matched")
+
+// ErrMalformedPrincipal is returned by Map for a principal it cannot parse.
+var ErrMalformedPrincipal = errors.New("malformed principal")
+
+// ruleParser is the rule grammar used by Hadoop's KerberosName.
+var ruleParser = regexp.MustCompile(
+	`\s*((DEFAULT)|(RULE:\[(\d*):([^\]]*)](\(([^)]*)\))?(s/([^/]*)/([^/]*)/(g)?)?))/?(L)?`)
+
+var nonSimple = regexp.MustCompile(`[/@]`)
+
+// A rule is a single parsed DEFAULT or RULE entry.
+type rule struct {
+	isDefault  bool
+	components int
+	format     string
+	match      *regexp.Regexp
+	from       *regexp.Regexp
+	to         string
+	global     bool
+	lower      bool
+	text       string
+}
+
+// Rules is an ordered set of auth_to_local rules for one service.
+type Rules struct {
+	defaultRealm string
+	rules        []rule
+}
+
+// NewRules parses the rules in text, separated by whitespace or newlines.
+// defaultRealm is the realm that DEFAULT applies to.
+func NewRules(defaultRealm string, text string) (*Rules, error) {
+	r := &Rules{defaultRealm: defaultRealm}
+
+	rest := strings.TrimSpace(text)
+	for len(rest) > 0 {
+		m := ruleParser.FindStringSubmatchIndex(rest)
+		if m == nil || m[0] != 0 {
+			return nil, fmt.Errorf("invalid auth_to_local rule: %q", rest)
+		}
+
+		sub := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return rest[m[2*i]:m[2*i+1]]
+		}
+
+		rl := rule{
+			isDefault: sub(2) != "",
+			lower:     sub(12) == "L",
+			text:      strings.TrimSpace(rest[m[0]:m[1]]),
+		}
+
+		if !rl.isDefault {
+			n, err := strconv.Atoi(sub(4))
+			if err != nil {
+				return nil, fmt.Errorf("invalid component count in rule %q", rl.text)
+			}
+			rl.components = n
+			rl.format = sub(5)
+			_, err = expand(rl.format, "", make([]string, n))
+			if err != nil {
+				return nil, fmt.Errorf("invalid format in rule %q: %v", rl.text, err)
+			}
+
+			if m[2*6] >= 0 {
+				rl.match, err = regexp.Compile("^(?:" + sub(7) + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("invalid regex in rule %q: %v", rl.text, err)
+				}
+			}
+			if m[2*8] >= 0 {
+				rl.from, err = regexp.Compile(sub(9))
+				if err != nil {
+					return nil, fmt.Errorf("invalid substitution in rule %q: %v", rl.text, err)
+				}
+				rl.to, err = javaReplacement(sub(10), rl.from.NumSubexp())
+				if err != nil {
+					return nil, fmt.Errorf("invalid replacement in rule %q: %v", rl.text, err)
+				}
+				rl.global = sub(11) == "g"
+			}
+		}
+
+		r.rules = append(r.rules, rl)
+		rest = strings.TrimSpace(rest[m[1]:])
+	}
+
+	return r, nil
+}
+
+// Map returns the short name for principal, using the first rule that
+// applies. ErrNoMatch is returned if no rule applies.
+func (r *Rules) Map(principal string) (string, error) {
+	components, realm, err := splitPrincipal(principal)
+	if err != nil {
+		return "", err
+	}
+	if realm == "" {
+		realm = r.defaultRealm
+	}
+
+	for _, rl := range r.rules {
+		name, ok, err := rl.apply(r.defaultRealm, realm, components)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return name, nil
+		}
+	}
+
+	return "", ErrNoMatch
+}
+
+// String returns the rules in their textual form, one per line.
+func (r *Rules) String() string {
+	lines := make([]string, 0, len(r.rules))
+	for _, rl := range r.rules {
+		lines = append(lines, rl.text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (rl rule) apply(defaultRealm, realm string, components []string) (
+	name string, ok bool, err error) {
+
+	if rl.isDefault {
+		if realm != defaultRealm {
+			return "", false, nil
+		}
+		name = components[0]
+	} else {
+		if len(components) != rl.components {
+			return "", false, nil
+		}
+
+		base, err := expand(rl.format, realm, components)
+		if err != nil {
+			return "", false, fmt.Errorf("rule %q: %v", rl.text, err)
+		}
+		if rl.match != nil && !rl.match.MatchString(base) {
+			return "", false, nil
+		}
+
+		name = base
+		if rl.from != nil {
+			name = substitute(rl.from, base, rl.to, rl.global)
+		}
+	}
+	if nonSimple.MatchString(name) {
+		return "", false, fmt.Errorf("non-simple name %q after rule %q", name, rl.text)
+	}
+
+	if rl.lower {
+		name = strings.ToLower(name)
+	}
+
+	return name, true, nil
+}
+
+// expand replaces $0 with the realm and $1..$n with the principal components.
+func expand(format, realm string, components []string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '$' {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		j := i + 1
+		for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			return "", fmt.Errorf("'$' without an index in format %q", format)
+		}
+
+		n, _ := strconv.Atoi(format[i+1 : j])
+		switch {
+		case n == 0:
+			b.WriteString(realm)
+		case n <= len(components):
+			b.WriteString(components[n-1])
+		default:
+			return "", fmt.Errorf("index %d out of range in format %q", n, format)
+		}
+		i = j - 1
+	}
+
+	return b.String(), nil
+}
+
+func substitute(from *regexp.Regexp, s, to string, global bool) string {
+	if global {
+		return from.ReplaceAllString(s, to)
+	}
+
+	loc := from.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return s
+	}
+	repl := from.ExpandString(nil, to, s, loc)
+	return s[:loc[0]] + string(repl) + s[loc[1]:]
+}
+
+// javaReplacement converts a java.util.regex replacement string, as used in
+// Hadoop rules, into a regexp.Expand template for a regex with ngroups
+// groups. As in Java, the digits after '$' are taken for as long as they
+// name an existing group.
+func javaReplacement(to string, ngroups int) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(to); i++ {
+		c := to[i]
+		switch {
+		case c == '\\':
+			i++
+			if i == len(to) {
+				return "", fmt.Errorf("trailing backslash in %q", to)
+			}
+			if to[i] == '$' {
+				b.WriteString("$$")
+			} else {
+				b.WriteByte(to[i])
+			}
+		case c == '$':
+			if i+1 == len(to) || to[i+1] < '0' || to[i+1] > '9' {
+				return "", fmt.Errorf("'$' without a group number in %q", to)
+			}
+			i++
+			n := int(to[i] - '0')
+			for i+1 < len(to) && to[i+1] >= '0' && to[i+1] <= '9' &&
+				n*10+int(to[i+1]-'0') <= ngroups {
+				i++
+				n = n*10 + int(to[i]-'0')
+			}
+			if n > ngroups {
+				return "", fmt.Errorf("no group %d in %q", n, to)
+			}
+			fmt.Fprintf(&b, "${%d}", n)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// splitPrincipal splits "a/b@REALM" into its components and realm, honouring
+// backslash escapes as krb5_parse_name does. The realm is empty if there is
+// none.
+func splitPrincipal(principal string) (components []string, realm string, err error) {
+	var cur strings.Builder
+	inRealm := false
+
+	for i := 0; i < len(principal); i++ {
+		c := principal[i]
+		switch {
+		case c == '\\' && i+1 < len(principal):
+			i++
+			cur.WriteByte(principal[i])
+		case c == '/' && !inRealm:
+			components = append(components, cur.String())
+			cur.Reset()
+		case c == '@' && !inRealm:
+			components = append(components, cur.String())
+			cur.Reset()
+			inRealm = true
+		default:
+			cur.WriteByte(c)
+		}
+	}
+
+	if inRealm {
+		realm = cur.String()
+		if realm == "" {
+			return nil, "", fmt.Errorf("%w: %q", ErrMalformedPrincipal, principal)
+		}
+	} else {
+		components = append(components, cur.String())
+	}
+	for _, c := range components {
+		if c == "" {
+			return nil, "", fmt.Errorf("%w: %q", ErrMalformedPrincipal, principal)
+		}
+	}
+
+	return components, realm, nil
+}
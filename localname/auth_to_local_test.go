@@ -0,0 +1,144 @@
+package localname
+
+import (
+	"errors"
+	"testing"
+)
+
+// The rules and principals of Hadoop's TestKerberosName and of its secure
+// mode documentation.
+func TestMapHadoop(t *testing.T) {
+	for _, tc := range []struct {
+		realm     string
+		rules     string
+		principal string
+		want      string
+		err       error
+	}{
+		{"APACHE.ORG", hadoopRules, "omalley@APACHE.ORG", "omalley", nil},
+		{"APACHE.ORG", hadoopRules, "hdfs/10.0.0.1@APACHE.ORG", "hdfs", nil},
+		{"APACHE.ORG", hadoopRules, "oom@YAHOO.COM", "oom", nil},
+		{"APACHE.ORG", hadoopRules, "johndoe/zoo@FOO.COM", "guest", nil},
+		{"APACHE.ORG", hadoopRules, "joe/admin@FOO.COM", "joe", nil},
+		{"APACHE.ORG", hadoopRules, "joe/root@FOO.COM", "root", nil},
+		{"APACHE.ORG", hadoopRules, "foo@ACME.COM", "", ErrNoMatch},
+		{"APACHE.ORG", hadoopRules, "root/joe@FOO.COM", "", ErrNoMatch},
+		{"APACHE.ORG", hadoopRules, "owen/owen/owen@FOO.COM", "", ErrNoMatch},
+
+		{"FOO.COM", lowerRules, "Joe@FOO.COM", "joe", nil},
+		{"FOO.COM", lowerRules, "Joe/root@FOO.COM", "joe", nil},
+		{"FOO.COM", lowerRules, "Joe/admin@FOO.COM", "joe", nil},
+		{"FOO.COM", lowerRules, "Joe/guestguest@FOO.COM", "joe", nil},
+
+		{"REALM.TLD", secureModeRules, "nn/host.realm.tld@REALM.TLD", "hdfs", nil},
+		{"REALM.TLD", secureModeRules, "dn/host.realm.tld@REALM.TLD", "hdfs", nil},
+		{"REALM.TLD", secureModeRules, "rm/host.realm.tld@REALM.TLD", "yarn", nil},
+		{"REALM.TLD", secureModeRules, "jhs/host.realm.tld@REALM.TLD", "mapred", nil},
+		{"REALM.TLD", secureModeRules, "alice@REALM.TLD", "alice", nil},
+		{"REALM.TLD", secureModeRules, "HTTP/host.realm.tld@REALM.TLD", "HTTP", nil},
+		{"REALM.TLD", secureModeRules, "alice@OTHER.TLD", "", ErrNoMatch},
+	} {
+		r, err := NewRules(tc.realm, tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Map(tc.principal)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("Map(%q) = %q, %v; want %q, %v", tc.principal, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+const hadoopRules = `RULE:[1:$1@$0](.*@YAHOO\.COM)s/@.*//
+RULE:[2:$1](johndoe)s/^.*$/guest/
+RULE:[2:$1;$2](^.*;admin$)s/;admin$//
+RULE:[2:$2](root)
+DEFAULT`
+
+const lowerRules = `RULE:[1:$1]/L
+RULE:[2:$1]/L
+RULE:[2:$1;$2](^.*;admin$)s/;admin$///L
+RULE:[2:$1;$2](^.*;guest$)s/;guest$//g/L
+DEFAULT`
+
+const secureModeRules = `RULE:[2:$1/$2@$0]([ndj]n/.*@REALM\.TLD)s/.*/hdfs/
+RULE:[2:$1/$2@$0]([rn]m/.*@REALM\.TLD)s/.*/yarn/
+RULE:[2:$1/$2@$0](jhs/.*@REALM\.TLD)s/.*/mapred/
+DEFAULT`
+
+func TestMapReplacement(t *testing.T) {
+	for _, tc := range []struct {
+		rule string
+		want string
+	}{
+		// Java takes $1 followed by x, where Go would look for group "1x"
+		{`RULE:[1:$1](.*)s/(.*)/$1x/`, "joex"},
+		{`RULE:[1:$1](.*)s/(j)(o)/$2$1/`, "oje"},
+		// $12 is group 1 and a 2 when there are fewer than 12 groups
+		{`RULE:[1:$1](.*)s/(.*)/$12/`, "joe2"},
+		{`RULE:[1:$1](.*)s/(.*)/$0-$1/`, "joe-joe"},
+		{`RULE:[1:$1](.*)s/(.*)/\$1/`, "$1"},
+		{`RULE:[1:$1](.*)s/o/0/`, "j0e"},
+		{`RULE:[1:$1_$1](.*)s/e/E/g`, "joE_joE"},
+	} {
+		r, err := NewRules("R", tc.rule)
+		if err != nil {
+			t.Errorf("NewRules(%q): %v", tc.rule, err)
+			continue
+		}
+		got, err := r.Map("joe@R")
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Map = %q, %v; want %q", tc.rule, got, err, tc.want)
+		}
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	r, err := NewRules("R", `RULE:[1:$1@$0] DEFAULT`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"", "@R", "joe@", "joe//x@R", "/x@R"} {
+		got, err := r.Map(p)
+		if !errors.Is(err, ErrMalformedPrincipal) {
+			t.Errorf("Map(%q) = %q, %v; want %v", p, got, err, ErrMalformedPrincipal)
+		}
+	}
+
+	// the result of the first rule still holds an '@'
+	_, err = r.Map("joe@R")
+	if err == nil || errors.Is(err, ErrNoMatch) {
+		t.Errorf("Map of a non-simple result = %v, want a rule error", err)
+	}
+}
+
+func TestNewRulesErrors(t *testing.T) {
+	for _, text := range []string{
+		`RULE:[1:$2]`,
+		`RULE:[1:$]`,
+		`RULE:[1:$1](a(b)`,
+		`RULE:[1:$1]s/(a)/$2/`,
+		`RULE:[1:$1]s/a/$x/`,
+		`RULE:[1:$1]s/a/b\/`,
+		`NOTARULE`,
+	} {
+		_, err := NewRules("R", text)
+		if err == nil {
+			t.Errorf("NewRules(%q) succeeded", text)
+		}
+	}
+}
+
+func TestMapNoRealm(t *testing.T) {
+	r, err := NewRules("R", `RULE:[2:$1@$0](.*@R)s/@.*// DEFAULT`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]string{"joe": "joe", "nn/host": "nn"} {
+		got, err := r.Map(p)
+		if err != nil || got != want {
+			t.Errorf("Map(%q) = %q, %v; want %q", p, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gssapi
+
+// This file provides in-memory krb5 keytabs, for giving a credential keys
+// that should never be written to disk.
+
+/*
+#cgo linux LDFLAGS: -lkrb5
+
+#include <stdlib.h>
+#include <string.h>
+#include <krb5.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// memoryKeytabSeq makes memory keytab names unique within the process.
+var memoryKeytabSeq uint64
+
+// A memoryKeytab is a krb5 MEMORY keytab. The library keeps its entries only
+// while some handle to it is open, so it holds one until close; a
+// credential acquired with its name holds another.
+type memoryKeytab struct {
+	name string
+	ctx  C.krb5_context
+	kt   C.krb5_keytab
+}
+
+// newMemoryKeytab creates a MEMORY keytab holding the entries of kt.
+func newMemoryKeytab(kt *keytab.Keytab) (*memoryKeytab, error) {
+	m := &memoryKeytab{
+		name: fmt.Sprintf("MEMORY:gssapi-%d-%d", os.Getpid(),
+			atomic.AddUint64(&memoryKeytabSeq, 1)),
+	}
+
+	code := C.krb5_init_context(&m.ctx)
+	if code != 0 {
+		return nil, fmt.Errorf("cannot initialize krb5 context (%d)", int32(code))
+	}
+
+	cname := C.CString(m.name)
+	defer C.free(unsafe.Pointer(cname))
+
+	code = C.krb5_kt_resolve(m.ctx, cname, &m.kt)
+	if code != 0 {
+		err := m.error(code)
+		C.krb5_free_context(m.ctx)
+		return nil, err
+	}
+
+	for _, e := range kt.Entries {
+		err := m.add(e)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *memoryKeytab) add(e keytab.Entry) error {
+	cprinc := C.CString(e.Principal.String())
+	defer C.free(unsafe.Pointer(cprinc))
+
+	var princ C.krb5_principal
+	code := C.krb5_parse_name(m.ctx, cprinc, &princ)
+	if code != 0 {
+		return m.error(code)
+	}
+	defer C.krb5_free_principal(m.ctx, princ)
+
+	// the library copies the key; wipe the C copy once it has
+	key := C.CBytes(e.Key)
+	defer C.free(key)
+	defer C.memset(key, 0, C.size_t(len(e.Key)))
+
+	entry := C.krb5_keytab_entry{
+		principal: princ,
+		timestamp: C.krb5_timestamp(e.Timestamp.Unix()),
+		vno:       C.krb5_kvno(e.KVNO),
+	}
+	entry.key.enctype = C.krb5_enctype(e.Enctype)
+	entry.key.length = C.uint(len(e.Key))
+	entry.key.contents = (*C.krb5_octet)(key)
+
+	code = C.krb5_kt_add_entry(m.ctx, m.kt, &entry)
+	if code != 0 {
+		return m.error(code)
+	}
+	return nil
+}
+
+// close closes the keytab handle. The entries are freed once no credential
+// refers to the keytab either.
+func (m *memoryKeytab) close() {
+	C.krb5_kt_close(m.ctx, m.kt)
+	C.krb5_free_context(m.ctx)
+}
+
+func (m *memoryKeytab) error(code C.krb5_error_code) error {
+	msg := C.krb5_get_error_message(m.ctx, code)
+	defer C.krb5_free_error_message(m.ctx, msg)
+
+	return fmt.Errorf("keytab %s: %s", m.name, C.GoString(msg))
+}
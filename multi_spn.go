@@ -0,0 +1,200 @@
+package gssapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lixiangyun/go-gssapi/krb5/keytab"
+)
+
+// ErrSPNNotAllowed is returned by SPNAcceptor.Target when the client
+// targeted a principal that is not in the allowlist.
+var ErrSPNNotAllowed = errors.New("target principal not allowed")
+
+// An SPNAcceptor accepts contexts for any principal found in one or more
+// keytabs, with a GSS_C_NO_NAME acceptor credential, optionally restricted
+// to an allowlist. After a context is established, Target tells which of
+// the keytab principals the client asked for, so that a service answering
+// for several host names can route or reject by virtual host.
+//
+// The krb5 mechanism reads one keytab per credential, and accepts for any
+// principal in it. So when several keytabs or an allowlist are given, the
+// allowed entries are copied into a MEMORY keytab for the credential: keys
+// of principals that are not allowed never reach it, and nothing is written
+// to disk.
+type SPNAcceptor struct {
+	cred       *CredId
+	principals []keytab.Principal
+	allow      []keytab.Principal
+	mkt        *memoryKeytab
+}
+
+// NewSPNAcceptor acquires an acceptor credential for the keytabs ktnames.
+// Each allow entry is a principal such as "HTTP/www.example.com@EXAMPLE.COM";
+// the realm may be left out to allow it in any realm. An empty allowlist
+// allows every principal in the keytabs. An entry that matches no keytab
+// principal is an error, to catch typos early.
+func NewSPNAcceptor(allow []string, ktnames ...string) (*SPNAcceptor, error) {
+	if len(ktnames) == 0 {
+		return nil, errors.New("no keytab given")
+	}
+
+	a := &SPNAcceptor{}
+	for _, s := range allow {
+		if !strings.Contains(s, "@") {
+			s += "@"
+		}
+		p, err := keytab.ParsePrincipal(s)
+		if err != nil {
+			return nil, err
+		}
+		a.allow = append(a.allow, p)
+	}
+
+	merged := keytab.New()
+	for _, name := range ktnames {
+		if !isFileKeytab(name) {
+			return nil, fmt.Errorf("keytab %s: only FILE keytabs are supported", name)
+		}
+		kt, err := keytab.Load(name)
+		if err != nil {
+			return nil, fmt.Errorf("keytab %s: %v", name, err)
+		}
+		merged.Entries = append(merged.Entries, kt.Entries...)
+	}
+	for _, p := range merged.Principals() {
+		if a.allowed(p) {
+			a.principals = append(a.principals, p)
+		}
+	}
+	if len(a.principals) == 0 {
+		return nil, fmt.Errorf("no allowed principal in keytabs %s",
+			strings.Join(ktnames, ", "))
+	}
+	for _, p := range a.allow {
+		if !a.inKeytab(p) {
+			return nil, fmt.Errorf("allowed principal %s is not in keytabs %s",
+				allowString(p), strings.Join(ktnames, ", "))
+		}
+	}
+
+	ktname := ktnames[0]
+	if len(ktnames) > 1 || len(a.allow) > 0 {
+		filtered := keytab.New()
+		for _, e := range merged.Entries {
+			if a.allowed(e.Principal) {
+				filtered.Entries = append(filtered.Entries, e)
+			}
+		}
+		mkt, err := newMemoryKeytab(filtered)
+		if err != nil {
+			return nil, err
+		}
+		a.mkt = mkt
+		ktname = mkt.name
+	}
+
+	cred, actualMechs, _, err := AcquireCredFrom(GSS_C_NO_NAME(), 0,
+		GSS_C_NO_OID_SET, GSS_C_ACCEPT, CredStore{CredStoreKeytab: ktname})
+	if err != nil {
+		a.closeKeytab()
+		return nil, err
+	}
+	actualMechs.Release()
+	a.cred = cred
+
+	return a, nil
+}
+
+// Cred returns the acceptor credential, to pass to AcceptSecContext. It is
+// owned by the SPNAcceptor.
+func (a *SPNAcceptor) Cred() *CredId {
+	return a.cred
+}
+
+// Principals returns the keytab principals that contexts may be accepted
+// for.
+func (a *SPNAcceptor) Principals() []keytab.Principal {
+	return append([]keytab.Principal(nil), a.principals...)
+}
+
+// Target returns the keytab principal that the client of an established
+// context targeted. It returns ErrSPNNotAllowed if that principal is not
+// allowed; the caller should then delete the context.
+func (a *SPNAcceptor) Target(ctx *CtxId) (keytab.Principal, error) {
+	srcName, targetName, _, _, _, _, _, err := ctx.InquireContext()
+	if err != nil {
+		return keytab.Principal{}, err
+	}
+	defer srcName.Release()
+	defer targetName.Release()
+
+	s, _, err := targetName.Display()
+	if err != nil {
+		return keytab.Principal{}, err
+	}
+	target, err := keytab.ParsePrincipal(s)
+	if err != nil {
+		return keytab.Principal{}, err
+	}
+
+	for _, p := range a.principals {
+		if p.Equal(target) {
+			return p, nil
+		}
+	}
+	return target, fmt.Errorf("%w: %s", ErrSPNNotAllowed, target)
+}
+
+// Close releases the credential and the memory keytab, if any.
+func (a *SPNAcceptor) Close() error {
+	err := a.cred.Release()
+	a.closeKeytab()
+	return err
+}
+
+func (a *SPNAcceptor) allowed(p keytab.Principal) bool {
+	if len(a.allow) == 0 {
+		return true
+	}
+	for _, q := range a.allow {
+		if allowMatch(q, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *SPNAcceptor) inKeytab(q keytab.Principal) bool {
+	for _, p := range a.principals {
+		if allowMatch(q, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *SPNAcceptor) closeKeytab() {
+	if a.mkt != nil {
+		a.mkt.close()
+		a.mkt = nil
+	}
+}
+
+// allowMatch reports whether the allowlist entry q matches p. An entry
+// without a realm matches any realm.
+func allowMatch(q, p keytab.Principal) bool {
+	if q.Realm == "" {
+		q.Realm = p.Realm
+	}
+	return q.Equal(p)
+}
+
+func allowString(p keytab.Principal) string {
+	s := p.String()
+	if p.Realm == "" {
+		s = strings.TrimSuffix(s, "@")
+	}
+	return s
+}
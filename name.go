@@ -21,6 +21,10 @@ package gssapi
 */
 import "C"
 
+import (
+	"github.com/lixiangyun/go-gssapi/localname"
+)
+
 // NewName initializes a new principal name.
 func NewName() *Name {
 	return &Name{}
@@ -89,6 +93,18 @@ func (n Name) String() string {
 	return s
 }
 
+// LocalName maps the displayed form of the name to a local short name using
+// the given auth_to_local rules.
+func (n Name) LocalName(rules *localname.Rules) (string, error) {
+	s, oid, err := n.Display()
+	if err != nil {
+		return "", err
+	}
+	oid.Release()
+
+	return rules.Map(s)
+}
+
 // Canonicalize returns a copy of this name, canonicalized for the specified
 // mechanism
 func (n Name) Canonicalize(mech_type *OID) (canonical *Name, err error) {
@@ -151,6 +151,18 @@ func (oid *OID) DebugString() string {
 		return "GSS_MECH_IAKERB"
 	case bytes.Equal(oid.Bytes(), GSS_MECH_NTLMSSP.Bytes()):
 		return "GSS_MECH_NTLMSSP"
+	case bytes.Equal(oid.Bytes(), GSS_KRB5_COPY_CCACHE_X.Bytes()):
+		return "GSS_KRB5_COPY_CCACHE_X"
+	case bytes.Equal(oid.Bytes(), GSS_KRB5_CRED_NO_CI_FLAGS_X.Bytes()):
+		return "GSS_KRB5_CRED_NO_CI_FLAGS_X"
+	case bytes.Equal(oid.Bytes(), GSS_KRB5_GET_CRED_IMPERSONATOR.Bytes()):
+		return "GSS_KRB5_GET_CRED_IMPERSONATOR"
+	case bytes.Equal(oid.Bytes(), GSS_C_INQ_SSPI_SESSION_KEY.Bytes()):
+		return "GSS_C_INQ_SSPI_SESSION_KEY"
+	case bytes.Equal(oid.Bytes(), GSS_C_INQ_NEGOEX_KEY.Bytes()):
+		return "GSS_C_INQ_NEGOEX_KEY"
+	case bytes.Equal(oid.Bytes(), GSS_C_INQ_NEGOEX_VERIFY_KEY.Bytes()):
+		return "GSS_C_INQ_NEGOEX_VERIFY_KEY"
 	}
 
 	return oid.String()
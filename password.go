@@ -0,0 +1,156 @@
+package gssapi
+
+// This file provides password-based credential acquisition.
+
+/*
+#include <string.h>
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+
+void
+zero_gss_buffer(gss_buffer_t b)
+{
+	if (b != NULL && b->value != NULL) {
+		memset(b->value, 0, b->length);
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPrincipalMismatch is returned by VerifyPassword when the acceptor
+// authenticated a different principal from the one the password was given
+// for.
+var ErrPrincipalMismatch = errors.New("authenticated principal does not match")
+
+// AcquireCredWithPassword implements gss_acquire_cred_with_password. It is
+// AcquireCred, but obtains the credentials using password instead of a
+// keytab or an existing credential cache. outputCredHandle, actualMechs must
+// be .Release()-ed by the caller
+func AcquireCredWithPassword(desiredName *Name, password *Buffer,
+	timeReq time.Duration, desiredMechs *OIDSet, credUsage CredUsage) (
+	outputCredHandle *CredId, actualMechs *OIDSet, timeRec time.Duration,
+	err error) {
+
+	min := C.OM_uint32(0)
+	actualMechs = NewOIDSet()
+	outputCredHandle = NewCredId()
+	timerec := C.OM_uint32(0)
+
+	maj := C.gss_acquire_cred_with_password(&min,
+		desiredName.C_gss_name_t,
+		password.C_gss_buffer_t,
+		C.OM_uint32(timeReq.Seconds()),
+		desiredMechs.C_gss_OID_set,
+		C.gss_cred_usage_t(credUsage),
+		&outputCredHandle.C_gss_cred_id_t,
+		&actualMechs.C_gss_OID_set,
+		&timerec)
+
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return outputCredHandle, actualMechs, time.Duration(timerec) * time.Second, nil
+}
+
+// VerifyPassword checks password for user. Getting a ticket with the
+// password is not enough on its own, since a spoofed KDC can hand out a
+// ticket for any password. So VerifyPassword also establishes a krb5 context
+// from the new credentials to service, accepting it with acceptorCredHandle,
+// which must hold the service key (for instance from a keytab). Only a KDC
+// that knows that key can have issued the ticket.
+func VerifyPassword(user *Name, password string, service *Name,
+	acceptorCredHandle *CredId) (err error) {
+
+	pw, err := MakeBufferString(password)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		C.zero_gss_buffer(pw.C_gss_buffer_t)
+		pw.Release()
+	}()
+
+	mechs, err := MakeOIDSet(GSS_MECH_KRB5)
+	if err != nil {
+		return err
+	}
+	defer mechs.Release()
+
+	initCred, actualMechs, _, err := AcquireCredWithPassword(user, pw, 0, mechs, GSS_C_INITIATE)
+	if err != nil {
+		return err
+	}
+	defer initCred.Release()
+	actualMechs.Release()
+
+	initCtx, acceptCtx := GSS_C_NO_CONTEXT, GSS_C_NO_CONTEXT
+	defer func() {
+		initCtx.Release()
+		acceptCtx.Release()
+	}()
+
+	var srcName *Name
+	defer func() {
+		srcName.Release()
+	}()
+
+	token := GSS_C_NO_BUFFER
+	acceptDone := false
+	for {
+		ctx, _, out, _, _, err := InitSecContext(initCred, initCtx, service,
+			GSS_MECH_KRB5, GSS_C_MUTUAL_FLAG, 0, GSS_C_NO_CHANNEL_BINDINGS, token)
+		token.Release()
+		if err != nil && err != ErrContinueNeeded {
+			return err
+		}
+		initCtx = ctx
+		initDone := err == nil
+
+		if out.Length() == 0 {
+			out.Release()
+			if initDone && acceptDone {
+				break
+			}
+			return errors.New("context establishment produced no token")
+		}
+
+		ctx, name, _, in, _, _, delegated, err := AcceptSecContext(acceptCtx,
+			acceptorCredHandle, out, GSS_C_NO_CHANNEL_BINDINGS)
+		out.Release()
+		if err != nil && err != ErrContinueNeeded {
+			return err
+		}
+		delegated.Release()
+		acceptCtx = ctx
+		acceptDone = err == nil
+
+		if acceptDone {
+			srcName = name
+		} else {
+			name.Release()
+		}
+
+		token = in
+		if initDone && acceptDone {
+			token.Release()
+			break
+		}
+	}
+
+	equal, err := srcName.Equal(*user)
+	if err != nil {
+		return err
+	}
+	if !equal {
+		return ErrPrincipalMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package gssapi
+
+// This file provides the GSS-API pseudo-random function of RFC 4401, for
+// deriving application keys from an established context.
+
+/*
+#include <gssapi/gssapi.h>
+#include <gssapi/gssapi_ext.h>
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// PseudoRandom implements gss_pseudo_random, deriving desiredLength bytes
+// from the context key selected by prfKey (GSS_C_PRF_KEY_FULL or
+// GSS_C_PRF_KEY_PARTIAL) and prfIn. Both ends of a context get the same
+// output for the same input.
+func (ctx *CtxId) PseudoRandom(prfKey int, prfIn []byte, desiredLength int) ([]byte, error) {
+	if desiredLength < 0 {
+		return nil, errors.New("negative PRF output length")
+	}
+
+	// an empty input still has to be passed as a buffer, not GSS_C_NO_BUFFER
+	var in *Buffer
+	var err error
+	if len(prfIn) == 0 {
+		in, err = MakeBuffer(allocMalloc)
+	} else {
+		in, err = MakeBufferBytes(prfIn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer in.Release()
+
+	out, err := MakeBuffer(allocGSSAPI)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Release()
+
+	min := C.OM_uint32(0)
+	maj := C.gss_pseudo_random(&min, ctx.C_gss_ctx_id_t, C.int(prfKey),
+		in.C_gss_buffer_t, C.ssize_t(desiredLength), out.C_gss_buffer_t)
+	err = StashLastStatus(maj, min)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// ExportedKeys are a pair of keys derived from a context, one for each
+// direction of traffic.
+type ExportedKeys struct {
+	InitiatorToAcceptor []byte
+	AcceptorToInitiator []byte
+}
+
+// ExportKeys derives a key of keyLen bytes for each direction from the full
+// context key, using label to separate them from keys derived for other
+// purposes. Both ends call it with the same label and get the same keys.
+func (ctx *CtxId) ExportKeys(label string, keyLen int) (*ExportedKeys, error) {
+	if keyLen <= 0 {
+		return nil, errors.New("exported key length must be positive")
+	}
+
+	i2a, err := ctx.PseudoRandom(GSS_C_PRF_KEY_FULL,
+		prfLabel(label, "initiator-to-acceptor"), keyLen)
+	if err != nil {
+		return nil, err
+	}
+	a2i, err := ctx.PseudoRandom(GSS_C_PRF_KEY_FULL,
+		prfLabel(label, "acceptor-to-initiator"), keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportedKeys{InitiatorToAcceptor: i2a, AcceptorToInitiator: a2i}, nil
+}
+
+// SendRecv returns the key for sending and the key for receiving, for the
+// initiator if locallyInitiated is true and for the acceptor otherwise.
+func (k *ExportedKeys) SendRecv(locallyInitiated bool) (send, recv []byte) {
+	if locallyInitiated {
+		return k.InitiatorToAcceptor, k.AcceptorToInitiator
+	}
+	return k.AcceptorToInitiator, k.InitiatorToAcceptor
+}
+
+// prfLabel returns the PRF input for a label and direction, separated by a
+// zero byte so that no label is a prefix of another's input.
+func prfLabel(label, direction string) []byte {
+	in := make([]byte, 0, len(label)+1+len(direction))
+	in = append(in, label...)
+	in = append(in, 0)
+	return append(in, direction...)
+}
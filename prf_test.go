@@ -0,0 +1,59 @@
+package gssapi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPRFLabel(t *testing.T) {
+	got := prfLabel("app", "initiator-to-acceptor")
+	if want := []byte("app\x00initiator-to-acceptor"); !bytes.Equal(got, want) {
+		t.Errorf("prfLabel = %q, want %q", got, want)
+	}
+
+	// a label that extends another does not start with the other's input
+	if bytes.HasPrefix(prfLabel("ab", "x"), prfLabel("a", "")) {
+		t.Error("the input for label ab starts with that for label a")
+	}
+}
+
+func TestExportKeys(t *testing.T) {
+	ini, acc := newTestContexts(t, uint32(GSS_C_MUTUAL_FLAG|GSS_C_INTEG_FLAG))
+	defer ini.DeleteSecContext()
+	defer acc.DeleteSecContext()
+
+	iniKeys, err := ini.ExportKeys("test", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accKeys, err := acc.ExportKeys("test", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iniSend, iniRecv := iniKeys.SendRecv(true)
+	accSend, accRecv := accKeys.SendRecv(false)
+	if len(iniSend) != 32 || len(iniRecv) != 32 {
+		t.Fatalf("key lengths %d and %d, want 32", len(iniSend), len(iniRecv))
+	}
+	if !bytes.Equal(iniSend, accRecv) || !bytes.Equal(accSend, iniRecv) {
+		t.Error("one end's send key is not the other's receive key")
+	}
+	if bytes.Equal(iniSend, iniRecv) {
+		t.Error("both directions have the same key")
+	}
+
+	other, err := ini.ExportKeys("other", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other.InitiatorToAcceptor, iniKeys.InitiatorToAcceptor) {
+		t.Error("different labels give the same key")
+	}
+
+	for _, n := range []int{0, -1} {
+		if _, err := ini.ExportKeys("test", n); err == nil {
+			t.Errorf("ExportKeys with length %d succeeded", n)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gssapi
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrContextDeleted is returned by SafeContext methods after Delete.
+var ErrContextDeleted = errors.New("security context deleted")
+
+// A SafeContext makes an established security context safe for concurrent
+// use, typically by a connection's reader and writer goroutines.
+//
+// All calls are serialized, including a Wrap in one goroutine with an
+// Unwrap in another: GSSAPI makes no promise that a context may be used
+// from two threads at once, and mechanisms such as krb5 update shared state
+// on both paths. Delete waits for calls in progress and makes later calls
+// fail with ErrContextDeleted, so the handle is never used after it is
+// freed.
+type SafeContext struct {
+	mu      sync.Mutex
+	ctx     *CtxId
+	deleted bool
+
+	// held, if set, is called with mu held before each call; tests use it
+	// to check that calls are serialized.
+	held func()
+}
+
+// NewSafeContext wraps an established context, which it takes ownership of:
+// it is deleted by Delete and must no longer be used directly.
+func NewSafeContext(ctx *CtxId) (*SafeContext, error) {
+	srcName, targetName, _, _, _, _, _, err := ctx.InquireContext()
+	if err != nil {
+		return nil, err
+	}
+	srcName.Release()
+	targetName.Release()
+
+	return &SafeContext{ctx: ctx}, nil
+}
+
+// GetMIC calls CtxId.GetMIC.
+func (s *SafeContext) GetMIC(qopReq QOP, messageBuffer *Buffer) (
+	messageToken *Buffer, err error) {
+
+	err = s.Do(func(ctx *CtxId) error {
+		messageToken, err = ctx.GetMIC(qopReq, messageBuffer)
+		return err
+	})
+	return messageToken, err
+}
+
+// VerifyMIC calls CtxId.VerifyMIC.
+func (s *SafeContext) VerifyMIC(messageBuffer *Buffer, tokenBuffer *Buffer) (
+	qopState QOP, err error) {
+
+	err = s.Do(func(ctx *CtxId) error {
+		qopState, err = ctx.VerifyMIC(messageBuffer, tokenBuffer)
+		return err
+	})
+	return qopState, err
+}
+
+// Wrap calls CtxId.Wrap.
+func (s *SafeContext) Wrap(confReq bool, qopReq QOP, inputMessageBuffer *Buffer) (
+	confState bool, outputMessageBuffer *Buffer, err error) {
+
+	err = s.Do(func(ctx *CtxId) error {
+		confState, outputMessageBuffer, err = ctx.Wrap(confReq, qopReq, inputMessageBuffer)
+		return err
+	})
+	return confState, outputMessageBuffer, err
+}
+
+// Unwrap calls CtxId.Unwrap.
+func (s *SafeContext) Unwrap(inputMessageBuffer *Buffer) (
+	outputMessageBuffer *Buffer, confState bool, qopState QOP, err error) {
+
+	err = s.Do(func(ctx *CtxId) error {
+		outputMessageBuffer, confState, qopState, err = ctx.Unwrap(inputMessageBuffer)
+		return err
+	})
+	return outputMessageBuffer, confState, qopState, err
+}
+
+// Do calls fn with the context while no other call is using it, for
+// operations SafeContext does not wrap. The context must not be retained
+// after fn returns.
+func (s *SafeContext) Do(fn func(ctx *CtxId) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleted {
+		return ErrContextDeleted
+	}
+	if s.held != nil {
+		s.held()
+	}
+
+	return fn(s.ctx)
+}
+
+// Delete waits for calls in progress to return and deletes the context.
+// It may be called more than once, and concurrently; only the first call
+// deletes the context.
+func (s *SafeContext) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleted {
+		return nil
+	}
+	s.deleted = true
+	return s.ctx.DeleteSecContext()
+}
+
+// Release is an alias for Delete.
+func (s *SafeContext) Release() error {
+	return s.Delete()
+}
@@ -0,0 +1,107 @@
+package gssapi
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestSafeContextConcurrent wraps in one goroutine while another unwraps,
+// on an established krb5 context, and deletes it under them; run it with
+// -race. Each Wrap and Unwrap must go through the lock, where the held hook
+// counts it: a call that skips the lock is missing from the count, and two
+// calls holding it at once race on the counter.
+func TestSafeContextConcurrent(t *testing.T) {
+	ini, acc := newTestContexts(t,
+		uint32(GSS_C_MUTUAL_FLAG|GSS_C_INTEG_FLAG|GSS_C_CONF_FLAG))
+	defer acc.DeleteSecContext()
+
+	const n = 200
+
+	// the acceptor's messages, for the initiator to unwrap
+	tokens := make([]*Buffer, n)
+	for i := range tokens {
+		msg, err := MakeBufferString(fmt.Sprintf("message %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, tokens[i], err = acc.Wrap(true, GSS_C_QOP_DEFAULT, msg)
+		msg.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tokens[i].Release()
+	}
+
+	s, err := NewSafeContext(ini)
+	if err != nil {
+		ini.DeleteSecContext()
+		t.Fatal(err)
+	}
+	held := 0
+	s.held = func() { held++ }
+
+	msg, err := MakeBufferString("message")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.Release()
+
+	var wrapped, unwrapped int
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			_, out, err := s.Wrap(true, GSS_C_QOP_DEFAULT, msg)
+			if err == ErrContextDeleted {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			out.Release()
+			wrapped++
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			out, _, _, err := s.Unwrap(tokens[i])
+			if err == ErrContextDeleted {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := out.String(), fmt.Sprintf("message %d", i); got != want {
+				t.Errorf("Unwrap = %q, want %q", got, want)
+			}
+			out.Release()
+			unwrapped++
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n/2; i++ {
+			s.Do(func(*CtxId) error { return nil })
+		}
+		s.Delete()
+	}()
+	wg.Wait()
+
+	if held != wrapped+unwrapped+n/2 {
+		t.Errorf("%d calls held the lock, want %d wraps, %d unwraps and %d others",
+			held, wrapped, unwrapped, n/2)
+	}
+
+	_, _, err = s.Wrap(true, GSS_C_QOP_DEFAULT, msg)
+	if err != ErrContextDeleted {
+		t.Errorf("Wrap after Delete = %v, want %v", err, ErrContextDeleted)
+	}
+	if err := s.Delete(); err != nil {
+		t.Errorf("second Delete = %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package gssapi
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionDone is returned by Step once the context has been established,
+// and by both Step and Result after Close.
+var ErrSessionDone = errors.New("security context already established or closed")
+
+// ErrSessionIncomplete is returned by Result before the context has been
+// established.
+var ErrSessionIncomplete = errors.New("security context not yet established")
+
+// A SessionResult describes an established security context. Its handles are
+// owned by the Initiator or Acceptor that produced it and are released by its
+// Close.
+type SessionResult struct {
+	// Peer is the name of the other side: the target for an initiator,
+	// the client for an acceptor.
+	Peer     *Name
+	PeerName string
+
+	Mech     *OID
+	Flags    ContextFlags
+	Lifetime time.Duration
+	Expiry   time.Time // the zero time if the context does not expire
+
+	// DelegatedCred holds the client's delegated credential on the
+	// acceptor side, or is nil if none was delegated.
+	DelegatedCred *CredId
+}
+
+// session holds what Initiator and Acceptor have in common.
+type session struct {
+	ctx    *CtxId
+	result *SessionResult
+	closed bool
+}
+
+// Context returns the security context, for Wrap, Unwrap and so on once
+// established. It is owned by the session.
+func (s *session) Context() *CtxId {
+	return s.ctx
+}
+
+// Done reports whether the context has been established.
+func (s *session) Done() bool {
+	return s.result != nil
+}
+
+// Result returns what was negotiated once the context is established.
+func (s *session) Result() (*SessionResult, error) {
+	switch {
+	case s.closed:
+		return nil, ErrSessionDone
+	case s.result == nil:
+		return nil, ErrSessionIncomplete
+	}
+	return s.result, nil
+}
+
+func (s *session) check() error {
+	if s.closed || s.result != nil {
+		return ErrSessionDone
+	}
+	return nil
+}
+
+// step finishes a call to gss_init_sec_context or gss_accept_sec_context:
+// it copies out the token and, on failure, deletes what is left of the
+// context so that the session cannot be stepped again.
+func (s *session) step(outputToken *Buffer, err error) ([]byte, bool, error) {
+	var out []byte
+	if outputToken.Length() > 0 {
+		out = outputToken.Bytes()
+	}
+	outputToken.Release()
+
+	switch err {
+	case nil:
+		return out, true, nil
+	case ErrContinueNeeded:
+		return out, false, nil
+	}
+
+	s.fail()
+	return out, false, err
+}
+
+// fail deletes what is left of the context after a failed step.
+func (s *session) fail() {
+	s.ctx.DeleteSecContext()
+	s.closed = true
+}
+
+func (s *session) close() error {
+	s.closed = true
+	err := s.ctx.DeleteSecContext()
+	if s.result != nil {
+		s.result.Peer.Release()
+		s.result.Mech.Release()
+		s.result.DelegatedCred.Release()
+		s.result = nil
+	}
+	return err
+}
+
+// An Initiator establishes a security context with a service, one token at
+// a time: call Step with nil first, send each returned token to the service
+// and feed its replies back into Step until it reports done.
+//
+// An Initiator is not safe for concurrent use.
+type Initiator struct {
+	session
+
+	// ChannelBindings and Lifetime, if set before the first Step, are
+	// passed to gss_init_sec_context.
+	ChannelBindings ChannelBindings
+	Lifetime        time.Duration
+
+	// RequiredFlags, if set before the first Step, are requested along
+	// with the flags given to NewInitiator, and Step fails with a
+	// *MissingFlagsError if the established context lacks any of them.
+	RequiredFlags ContextFlags
+
+	cred   *CredId
+	target *Name
+	mech   *OID
+	flags  uint32
+}
+
+// NewInitiator returns an Initiator for target, a name of type nameType, for
+// instance "HTTP@www.example.com" with GSS_C_NT_HOSTBASED_SERVICE. cred may
+// be nil for the default credential; it is not released by the Initiator and
+// must stay valid until the context is established. mech may be nil for the
+// default mechanism.
+func NewInitiator(cred *CredId, target string, nameType *OID, mech *OID,
+	reqFlags uint32) (*Initiator, error) {
+
+	b, err := MakeBufferString(target)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Release()
+
+	name, err := b.Name(nameType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Initiator{
+		session: session{ctx: NewCtxId()},
+		cred:    cred,
+		target:  name,
+		mech:    mech,
+		flags:   reqFlags,
+	}, nil
+}
+
+// Step processes a token from the service (nil on the first call) and
+// returns the token to send back, if any, and whether the context is now
+// established. On error out may still hold a token for the service.
+func (i *Initiator) Step(in []byte) (out []byte, done bool, err error) {
+	if err := i.check(); err != nil {
+		return nil, false, err
+	}
+
+	inputToken := GSS_C_NO_BUFFER
+	if len(in) > 0 {
+		inputToken, err = MakeBufferBytes(in)
+		if err != nil {
+			return nil, false, err
+		}
+		defer inputToken.Release()
+	}
+
+	actualMech, outputToken, retFlags, timeRec, err := initSecContext(i.cred,
+		i.ctx, i.target, i.mech, i.flags|uint32(i.RequiredFlags), i.Lifetime,
+		i.ChannelBindings, inputToken)
+	out, done, err = i.step(outputToken, err)
+	if !done {
+		return out, false, err
+	}
+	err = CheckFlags(retFlags, i.RequiredFlags)
+	if err != nil {
+		i.fail()
+		return out, false, err
+	}
+
+	peer, err := i.target.Duplicate()
+	if err != nil {
+		i.close()
+		return out, false, err
+	}
+	i.result = &SessionResult{
+		Peer:     peer,
+		PeerName: peer.String(),
+		Mech:     actualMech,
+		Flags:    ContextFlags(retFlags),
+		Lifetime: timeRec,
+		Expiry:   expiryFor(timeRec),
+	}
+	return out, true, nil
+}
+
+// Close deletes the security context and releases the handles owned by the
+// Initiator, including those in its SessionResult.
+func (i *Initiator) Close() error {
+	err := i.close()
+	i.target.Release()
+	return err
+}
+
+// An Acceptor establishes a security context with a client, one token at a
+// time: call Step with each token from the client and send back the tokens
+// it returns until it reports done.
+//
+// An Acceptor is not safe for concurrent use.
+type Acceptor struct {
+	session
+
+	// ChannelBindings, if set before the first Step, are passed to
+	// gss_accept_sec_context.
+	ChannelBindings ChannelBindings
+
+	// RequiredFlags, if set, are the services the client must have
+	// negotiated: Step fails with a *MissingFlagsError if the established
+	// context lacks any of them.
+	RequiredFlags ContextFlags
+
+	cred *CredId
+}
+
+// NewAcceptor returns an Acceptor using cred, or the default acceptor
+// credential if cred is nil. cred is not released by the Acceptor and must
+// stay valid until the context is established, so it can be shared by many
+// acceptors.
+func NewAcceptor(cred *CredId) *Acceptor {
+	return &Acceptor{
+		session: session{ctx: NewCtxId()},
+		cred:    cred,
+	}
+}
+
+// Step processes a token from the client and returns the token to send
+// back, if any, and whether the context is now established. On error out
+// may still hold an error token for the client.
+func (a *Acceptor) Step(in []byte) (out []byte, done bool, err error) {
+	if err := a.check(); err != nil {
+		return nil, false, err
+	}
+
+	inputToken, err := MakeBufferBytes(in)
+	if err != nil {
+		return nil, false, err
+	}
+	defer inputToken.Release()
+
+	srcName, actualMech, outputToken, retFlags, timeRec, delegated, err :=
+		acceptSecContext(a.ctx, a.cred, inputToken, a.ChannelBindings)
+	out, done, err = a.step(outputToken, err)
+	if err == nil && done {
+		err = CheckFlags(retFlags, a.RequiredFlags)
+		if err != nil {
+			a.fail()
+			done = false
+		}
+	}
+	if !done {
+		srcName.Release()
+		delegated.Release()
+		return out, false, err
+	}
+
+	if delegated.C_gss_cred_id_t == nil {
+		delegated = nil
+	}
+	a.result = &SessionResult{
+		Peer:          srcName,
+		PeerName:      srcName.String(),
+		Mech:          actualMech,
+		Flags:         ContextFlags(retFlags),
+		Lifetime:      timeRec,
+		Expiry:        expiryFor(timeRec),
+		DelegatedCred: delegated,
+	}
+	return out, true, nil
+}
+
+// Close deletes the security context and releases the handles owned by the
+// Acceptor, including those in its SessionResult.
+func (a *Acceptor) Close() error {
+	return a.close()
+}
@@ -19,6 +19,17 @@ const (
 
 type SPNEGO struct {
 	Cerd *gssapi.CredId
+
+	// AnonymousPolicy decides whether NegotiateVerification accepts
+	// anonymous clients. The zero value refuses them.
+	AnonymousPolicy gssapi.AnonymousPolicy
+
+	// RequiredFlags are requested by NegotiateAddition, and
+	// NegotiateVerification refuses clients whose context lacks any of them
+	// with a *gssapi.MissingFlagsError.
+	RequiredFlags gssapi.ContextFlags
+
+	reqFlags uint32
 }
 
 func NewSPNEGO(username string) (*SPNEGO, error) {
@@ -41,6 +52,17 @@ func NewSPNEGO(username string) (*SPNEGO, error) {
 	return &SPNEGO{Cerd:clientCred},nil
 }
 
+// NewAnonymousSPNEGO returns a client that authenticates anonymously, via
+// anonymous PKINIT with the krb5 mechanism.
+func NewAnonymousSPNEGO() (*SPNEGO, error) {
+	clientCred, _, err := gssapi.AcquireAnonymousCred(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SPNEGO{Cerd: clientCred, reqFlags: gssapi.GSS_C_ANON_FLAG}, nil
+}
+
 func (this *SPNEGO)Release() {
 	this.Cerd.Release()
 }
@@ -49,7 +71,7 @@ func (this *SPNEGO)NegotiateAddition(req http.Header, spname *gssapi.Name ) erro
 
 	ctx, _, token, _, _, err := gssapi.InitSecContext(
 		this.Cerd, gssapi.GSS_C_NO_CONTEXT, spname, gssapi.GSS_C_NO_OID,
-		0,0,gssapi.GSS_C_NO_CHANNEL_BINDINGS,gssapi.GSS_C_NO_BUFFER)
+		this.reqFlags|uint32(this.RequiredFlags),0,gssapi.GSS_C_NO_CHANNEL_BINDINGS,gssapi.GSS_C_NO_BUFFER)
 
 	defer token.Release()
 
@@ -91,7 +113,7 @@ func (this *SPNEGO)NegotiateVerification(inHeader, outHeader http.Header) (strin
 	}
 
 	// FIXME: GSS_S_CONTINUED_NEEDED handling?
-	ctx, srcName, _, outputToken, _, _, delegatedCredHandle, err :=
+	ctx, srcName, _, outputToken, retFlags, _, delegatedCredHandle, err :=
 		gssapi.AcceptSecContext(gssapi.GSS_C_NO_CONTEXT, this.Cerd, inputToken, gssapi.GSS_C_NO_CHANNEL_BINDINGS)
 
 	if err != nil {
@@ -103,6 +125,15 @@ func (this *SPNEGO)NegotiateVerification(inHeader, outHeader http.Header) (strin
 	defer outputToken.Release()
 	defer srcName.Release()
 
+	err = gssapi.CheckAnonymous(this.AnonymousPolicy, srcName, retFlags)
+	if err != nil {
+		return "", http.StatusForbidden, err
+	}
+	err = gssapi.CheckFlags(retFlags, this.RequiredFlags)
+	if err != nil {
+		return "", http.StatusForbidden, err
+	}
+
 	addSPNEGONegotiate(outHeader, WWW_AUTH_HEAD, outputToken)
 	return srcName.String(), http.StatusOK, nil
 }
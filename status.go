@@ -156,6 +156,13 @@ func StashLastStatus(major, minor C.OM_uint32) error {
 	return lastStatus.GoError()
 }
 
+// MakeStatus returns the error for a major and minor status, or nil if major
+// is not an error. It lets packages wrapping mechanism-specific calls, which
+// cannot pass C types across packages, report status the same way.
+func MakeStatus(major, minor uint32) error {
+	return StashLastStatus(C.OM_uint32(major), C.OM_uint32(minor))
+}
+
 // GoError returns an untyped error interface object.
 func (e *Error) GoError() error {
 	if e.Major.IsError() {
@@ -0,0 +1,208 @@
+package gssapi
+
+import (
+	"container/list"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A CcacheLocator returns the credential cache holding the tickets of a user
+// principal, for instance "DIR:/var/lib/app/ccaches/alice@EXAMPLE.COM" or
+// "KEYRING:persistent:1000".
+type CcacheLocator func(principal string) string
+
+// DirCcacheLocator keeps each user's tickets in a DIR collection of its own
+// under dir.
+func DirCcacheLocator(dir string) CcacheLocator {
+	return func(principal string) string {
+		return "DIR:" + filepath.Join(dir, ccacheFileName(principal))
+	}
+}
+
+// MemoryCcacheLocator keeps each user's tickets in a MEMORY cache named after
+// the principal. Memory caches start out empty; fill them with StoreCredInto.
+func MemoryCcacheLocator(prefix string) CcacheLocator {
+	return func(principal string) string {
+		return "MEMORY:" + prefix + principal
+	}
+}
+
+func ccacheFileName(principal string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(principal)
+}
+
+// A UserCredManager holds initiator credentials for many users at once, one
+// CredId per principal, each bound to the user's own credential cache. Since
+// every credential names its cache explicitly, concurrent InitSecContext
+// calls for different users never go through the process default cache and
+// cannot pick up each other's tickets.
+//
+// At most capacity credentials are kept; the least recently used is evicted
+// beyond that. A credential with less than minLifetime left is re-acquired
+// from its cache on lookup, to pick up tickets renewed in the meantime.
+type UserCredManager struct {
+	locate      CcacheLocator
+	capacity    int
+	minLifetime time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *userCred, most recently used first
+	entries map[string]*list.Element
+}
+
+type userCred struct {
+	principal string
+	cred      *CredId
+	expiry    time.Time
+	refs      int
+	evicted   bool
+}
+
+// NewUserCredManager returns a manager finding caches with locate.
+func NewUserCredManager(locate CcacheLocator, capacity int,
+	minLifetime time.Duration) *UserCredManager {
+
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &UserCredManager{
+		locate:      locate,
+		capacity:    capacity,
+		minLifetime: minLifetime,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+// Do calls fn with the credential for principal, acquiring it from the
+// user's cache if needed. The credential must not be used or retained after
+// fn returns; it stays valid until then even if evicted concurrently.
+func (m *UserCredManager) Do(principal string, fn func(cred *CredId) error) error {
+	uc, err := m.get(principal)
+	if err != nil {
+		return err
+	}
+	defer m.put(uc)
+
+	return fn(uc.cred)
+}
+
+// Forget drops the credential held for principal, if any.
+func (m *UserCredManager) Forget(principal string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[principal]; ok {
+		m.evict(e)
+	}
+}
+
+// Len returns the number of credentials held.
+func (m *UserCredManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lru.Len()
+}
+
+// Close releases all the credentials held. Credentials in use by Do are
+// released when fn returns.
+func (m *UserCredManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for m.lru.Len() > 0 {
+		m.evict(m.lru.Back())
+	}
+}
+
+func (m *UserCredManager) get(principal string) (*userCred, error) {
+	m.mu.Lock()
+	if e, ok := m.entries[principal]; ok {
+		uc := e.Value.(*userCred)
+		if uc.expiry.IsZero() || time.Until(uc.expiry) >= m.minLifetime {
+			m.lru.MoveToFront(e)
+			uc.refs++
+			m.mu.Unlock()
+			return uc, nil
+		}
+		m.evict(e)
+	}
+	m.mu.Unlock()
+
+	// acquire without holding the lock, it may need to read from disk
+	cred, expiry, err := m.acquire(principal)
+	if err != nil {
+		return nil, err
+	}
+	uc := &userCred{principal: principal, cred: cred, expiry: expiry, refs: 1}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[principal]; ok {
+		// raced with another lookup for the same principal
+		m.evict(e)
+	}
+	m.entries[principal] = m.lru.PushFront(uc)
+	for m.lru.Len() > m.capacity {
+		m.evict(m.lru.Back())
+	}
+
+	return uc, nil
+}
+
+func (m *UserCredManager) put(uc *userCred) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uc.refs--
+	if uc.evicted && uc.refs == 0 {
+		uc.cred.Release()
+	}
+}
+
+// evict removes an entry, releasing its credential unless it is in use.
+// m.mu must be held.
+func (m *UserCredManager) evict(e *list.Element) {
+	uc := e.Value.(*userCred)
+	m.lru.Remove(e)
+	delete(m.entries, uc.principal)
+
+	uc.evicted = true
+	if uc.refs == 0 {
+		uc.cred.Release()
+	}
+}
+
+func (m *UserCredManager) acquire(principal string) (*CredId, time.Time, error) {
+	b, err := MakeBufferString(principal)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer b.Release()
+
+	name, err := b.Name(GSS_KRB5_NT_PRINCIPAL_NAME)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer name.Release()
+
+	mechs, err := MakeOIDSet(GSS_MECH_KRB5)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer mechs.Release()
+
+	store := CredStore{CredStoreCcache: m.locate(principal)}
+	cred, actualMechs, lifetime, err := AcquireCredFrom(name, 0, mechs,
+		GSS_C_INITIATE, store)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	actualMechs.Release()
+
+	return cred, expiryFor(lifetime), nil
+}